@@ -2,10 +2,13 @@ package cgroups
 
 import (
 	"fmt"
+	"github.com/phper95/tinydocker/enum"
 	"github.com/phper95/tinydocker/pkg/logger"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 /**
@@ -57,10 +60,20 @@ docker中cgroup默认路径： /sys/fs/cgroup/system.slice/docker-<container-id>
 const (
 	MemoryMax   = "memory.max"     // 内存限制配置文件，用于设置cgroup的内存上限
 	CpuMax      = "cpu.max"        // CPU限制配置文件，用于设置cgroup的CPU使用上限
+	PidsMax     = "pids.max"       // 进程数限制配置文件，用于设置cgroup中最多可以创建的进程数
 	CgroupProcs = "cgroup.procs"   // cgroup进程列表文件，用于将进程加入指定的cgroup
 	CgroupRoot  = "/sys/fs/cgroup" // cgroup挂载根目录，是Linux系统中管理控制组的默认路径
+
+	MemoryEvents         = "memory.events"          // 内存事件计数文件，oom_kill 记录被 OOM 杀死的进程数
+	CgroupFreeze         = "cgroup.freeze"          // 冻结控制文件，写入1冻结cgroup中的所有进程，写入0解冻
+	CgroupEvents         = "cgroup.events"          // cgroup事件文件，frozen 字段表示冻结是否已经完成
+	CgroupControllers    = "cgroup.controllers"     // 当前cgroup可用的控制器列表
+	CgroupSubtreeControl = "cgroup.subtree_control" // 向子cgroup开放的控制器列表
 )
 
+// 容器cgroup需要用到的控制器
+var subtreeControllers = []string{"cpu", "memory", "pids"}
+
 type CGroupManager struct {
 	path string
 }
@@ -83,6 +96,14 @@ func NewCGroupManager(name string) *CGroupManager {
 	// 拼接cgroup路径
 	cgroupPath := filepath.Join(CgroupRoot, name)
 
+	// 多级cgroup需要在父级开启控制器，子cgroup中才会出现memory.max等文件
+	if parent := filepath.Dir(cgroupPath); parent != CgroupRoot {
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			logger.Error("Error creating parent cgroup: %v", err)
+		}
+		enableSubtreeControllers(parent)
+	}
+
 	// 检查cgroup路径是否存在
 	if _, err := os.Stat(cgroupPath); os.IsNotExist(err) {
 		// 如果路径不存在，尝试创建路径
@@ -100,6 +121,95 @@ func NewCGroupManager(name string) *CGroupManager {
 	return &cgroupManager
 }
 
+// ContainerCgroupName 返回容器对应的cgroup名称（相对于CgroupRoot），每个容器拥有独立的cgroup
+func ContainerCgroupName(containerID string) string {
+	return filepath.Join(enum.AppName, containerID)
+}
+
+// enableSubtreeControllers 在dir的cgroup.subtree_control中开启容器需要的控制器
+func enableSubtreeControllers(dir string) {
+	available, err := os.ReadFile(filepath.Join(dir, CgroupControllers))
+	if err != nil {
+		logger.Error("Error reading cgroup controllers: %v", err)
+		return
+	}
+	enabled := strings.Fields(string(available))
+	for _, ctrl := range subtreeControllers {
+		found := false
+		for _, e := range enabled {
+			if e == ctrl {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, CgroupSubtreeControl), []byte("+"+ctrl), 0644); err != nil {
+			logger.Warn("Error enabling cgroup controller %s: %v", ctrl, err)
+		}
+	}
+}
+
+// Path 返回cgroup的完整路径
+func (c *CGroupManager) Path() string {
+	return c.path
+}
+
+// OOMKillCount 读取指定cgroup中被 OOM killer 杀死的进程数，读取失败时返回0
+func OOMKillCount(name string) int {
+	data, err := os.ReadFile(filepath.Join(CgroupRoot, name, MemoryEvents))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			n, _ := strconv.Atoi(fields[1])
+			return n
+		}
+	}
+	return 0
+}
+
+// SetFrozen 通过 cgroup v2 的 freezer 冻结或解冻指定cgroup中的所有进程，并等待操作完成
+func SetFrozen(name string, frozen bool) error {
+	cgroupPath := filepath.Join(CgroupRoot, name)
+	value, want := "0", "frozen 0"
+	if frozen {
+		value, want = "1", "frozen 1"
+	}
+	if err := os.WriteFile(filepath.Join(cgroupPath, CgroupFreeze), []byte(value), 0644); err != nil {
+		logger.Error("Error writing cgroup freeze: %v", err)
+		return err
+	}
+
+	// 冻结是异步完成的，轮询 cgroup.events 直到状态生效
+	for i := 0; i < 100; i++ {
+		data, err := os.ReadFile(filepath.Join(cgroupPath, CgroupEvents))
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) == want {
+				return nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out waiting for cgroup %s to become %q", name, want)
+}
+
+// Remove 删除指定名称的cgroup目录，cgroup中必须已经没有进程
+func Remove(name string) error {
+	cgroupPath := filepath.Join(CgroupRoot, name)
+	if err := os.Remove(cgroupPath); err != nil && !os.IsNotExist(err) {
+		logger.Error("Error removing cgroup %s: %v", cgroupPath, err)
+		return err
+	}
+	return nil
+}
+
 // Apply 将给定的进程ID（pid）加入到 cgroup 中。
 //
 // 参数：
@@ -185,6 +295,19 @@ func (c *CGroupManager) SetCPULimit(cpusStr string) error {
 	return nil
 }
 
+// SetPidsLimit 设置cgroup中最多可以创建的进程数，limit 小于等于0表示不限制
+func (c *CGroupManager) SetPidsLimit(limit int64) error {
+	value := "max"
+	if limit > 0 {
+		value = strconv.FormatInt(limit, 10)
+	}
+	if err := os.WriteFile(filepath.Join(c.path, PidsMax), []byte(value), 0644); err != nil {
+		logger.Error("Error setting pids limit: %v", err)
+		return err
+	}
+	return nil
+}
+
 // Cleanup 删除由c.path指定的目录及其所有子目录和文件。
 // 如果删除过程中发生错误，会记录错误日志并返回错误。
 // 如果没有错误发生，则返回nil。
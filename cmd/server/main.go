@@ -23,6 +23,8 @@ func main() {
 
 	// 创建 Gin 引擎
 	r := gin.New()
+	// handler 中的 panic 返回 500，不会导致服务退出
+	r.Use(gin.Recovery())
 
 	// 设置路由
 	routes.SetupRoutes(r)
@@ -1,11 +1,20 @@
 package commands
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/phper95/tinydocker/container/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/phper95/tinydocker/container"
 	"github.com/phper95/tinydocker/image"
+	"github.com/phper95/tinydocker/oci"
+	"github.com/phper95/tinydocker/pkg/filters"
 	"github.com/phper95/tinydocker/pkg/logger"
 	"github.com/urfave/cli"
 )
@@ -20,80 +29,147 @@ var InitCommand = cli.Command{
 		return container.InitContainerProcess()
 	},
 }
+
+// Internal command used to supervise a detached container after the CLI exits
+var ShimCommand = cli.Command{
+	Name:   "shim",
+	Usage:  "Internal: monitor a detached container process. Do not call it outside",
+	Hidden: true,
+	Action: func(ctx *cli.Context) error {
+		containerID := ctx.Args().First()
+		if containerID == "" {
+			return errors.New("container id cannot be empty")
+		}
+		return container.RunShim(containerID)
+	},
+}
+
+// run 和 create 共用的容器参数
+var runFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "name",
+		Usage: "Assign a name to the container",
+	},
+	&cli.BoolFlag{
+		Name:  "it",
+		Usage: "Interactive mode with pseudo-TTY",
+	},
+	&cli.BoolFlag{
+		Name:  "d",
+		Usage: "Run container in detached mode (background)",
+	},
+	&cli.StringFlag{
+		Name:  "m",
+		Usage: "Memory limit for the container (e.g., 512m, 1g)",
+	},
+	&cli.StringFlag{
+		Name:  "cpus",
+		Usage: "CPU limit for the container (e.g., 1.5)",
+	},
+	&cli.Int64Flag{
+		Name:  "pids-limit",
+		Usage: "Maximum number of processes in the container (0 for unlimited)",
+	},
+	&cli.StringFlag{
+		Name:  "v",
+		Usage: "Bind mount a volume (host_dir:container_dir)",
+	},
+	&cli.StringSliceFlag{
+		Name:  "e",
+		Usage: "Set environment variables (e.g., -e KEY=VALUE)",
+	},
+	&cli.StringSliceFlag{
+		Name:  "env-file",
+		Usage: "Read in a file of environment variables",
+	},
+	&cli.StringFlag{
+		Name:  "net",
+		Usage: "container network",
+	},
+	&cli.StringSliceFlag{
+		Name:  "p",
+		Usage: "port mapping",
+	},
+	&cli.StringSliceFlag{
+		Name:  "ulimit",
+		Usage: "Ulimit options (e.g., --ulimit nofile=1024:2048, -1 for unlimited)",
+	},
+	&cli.StringFlag{
+		Name:  "stop-signal",
+		Usage: "Signal to stop the container",
+		Value: "SIGTERM",
+	},
+	&cli.StringFlag{
+		Name:  "workdir, w",
+		Usage: "Working directory inside the container",
+	},
+	&cli.StringFlag{
+		Name:  "user, u",
+		Usage: "Username or UID (format: <name|uid>[:<group|gid>])",
+	},
+	&cli.StringFlag{
+		Name:  "hostname",
+		Usage: "Container host name (default is the first 12 characters of the container ID)",
+	},
+	&cli.StringFlag{
+		Name:  "domainname",
+		Usage: "Container NIS domain name",
+	},
+	&cli.BoolFlag{
+		Name:  "rm",
+		Usage: "Automatically remove the container when it exits",
+	},
+	&cli.BoolFlag{
+		Name:  "init",
+		Usage: "Run an init inside the container that forwards signals and reaps processes",
+	},
+	&cli.StringSliceFlag{
+		Name:  "label, l",
+		Usage: "Set metadata on the container (e.g., --label team=infra)",
+	},
+	&cli.StringFlag{
+		Name:  "hooks",
+		Usage: "Path to a JSON file of prestart/poststart/poststop hooks (OCI hooks format)",
+	},
+	&cli.StringFlag{
+		Name:  "health-cmd",
+		Usage: "Command to run inside the container to check health",
+	},
+	&cli.DurationFlag{
+		Name:  "health-interval",
+		Usage: "Time between running the check (default 30s)",
+	},
+	&cli.DurationFlag{
+		Name:  "health-timeout",
+		Usage: "Maximum time to allow one check to run (default 30s)",
+	},
+	&cli.IntFlag{
+		Name:  "health-retries",
+		Usage: "Consecutive failures needed to report unhealthy (default 3)",
+	},
+	&cli.DurationFlag{
+		Name:  "health-start-period",
+		Usage: "Start period for the container to initialize before failures count towards retries",
+	},
+	&cli.StringFlag{
+		Name:  "restart",
+		Usage: "Restart policy to apply when a container exits (no, on-failure[:max-retries], always, unless-stopped)",
+		Value: "no",
+	},
+}
+
 var RunCommand = cli.Command{
 	// 命令名称
 	Name:  "run",
 	Usage: "Run a command in a new container",
 	// 命令参数
-	Flags: []cli.Flag{
-		&cli.StringFlag{
-			Name:  "name",
-			Usage: "Assign a name to the container",
-		},
-		&cli.BoolFlag{
-			Name:  "it",
-			Usage: "Interactive mode with pseudo-TTY",
-		},
-		&cli.BoolFlag{
-			Name:  "d",
-			Usage: "Run container in detached mode (background)",
-		},
-		&cli.StringFlag{
-			Name:  "m",
-			Usage: "Memory limit for the container (e.g., 512m, 1g)",
-		},
-		&cli.StringFlag{
-			Name:  "cpus",
-			Usage: "CPU limit for the container (e.g., 1.5)",
-		},
-		&cli.StringFlag{
-			Name:  "v",
-			Usage: "Bind mount a volume (host_dir:container_dir)",
-		},
-		&cli.StringSliceFlag{
-			Name:  "e",
-			Usage: "Set environment variables (e.g., -e KEY=VALUE)",
-		},
-		&cli.StringFlag{
-			Name:  "net",
-			Usage: "container network",
-		},
-		&cli.StringSliceFlag{
-			Name:  "p",
-			Usage: "port mapping",
-		},
-	},
+	Flags: runFlags,
 	Action: func(ctx *cli.Context) error {
-		// 获取命令参数列表
-		args := ctx.Args()
-		logger.Debug("args:", args)
-		// 命令行参数校验
-		if len(args) < 2 {
-			return errors.New("Usage: tinydocker run [OPTIONS] IMAGE COMMAND")
-			// return errors.New("Usage: tinydocker run [OPTIONS] IMAGE COMMAND")
-		}
-		name := ctx.String("name")
-		if name == "" {
-			return errors.New("Container name cannot be empty")
+		info, err := parseContainerInfo(ctx, "run")
+		if err != nil {
+			return err
 		}
-		enableTTY := ctx.Bool("it")
-		detach := ctx.Bool("d")
-
-		if enableTTY && detach {
-			logger.Error("-it and -d cannot be used together")
-			return errors.New("-it and -d cannot be used together")
-		}
-
-		memoryLimit := ctx.String("m")
-		cpuLimit := ctx.String("cpus")
-		volume := ctx.String("v")
-		envVars := ctx.StringSlice("e")
-		imageName := ctx.Args().Get(0)
-		network := ctx.String("net")
-		portMapping := ctx.StringSlice("p")
-		logger.Debug("enableTTY:", enableTTY, "detach:", detach,
-			"memoryLimit:", memoryLimit, "cpuLimit:", cpuLimit, "volume:", volume, "image:", imageName, "envVars:", envVars)
-		err := container.Run(args[1:], name, enableTTY, detach, memoryLimit, cpuLimit, volume, imageName, envVars, network, portMapping)
+		err = container.Run(info)
 		if err != nil {
 			logger.Error("Run container error:", err)
 		}
@@ -101,6 +177,139 @@ var RunCommand = cli.Command{
 	},
 }
 
+// docker create [OPTIONS] IMAGE COMMAND
+var CreateCommand = cli.Command{
+	Name:  "create",
+	Usage: "Create a new container without starting it",
+	Flags: runFlags,
+	Action: func(ctx *cli.Context) error {
+		info, err := parseContainerInfo(ctx, "create")
+		if err != nil {
+			return err
+		}
+		if err := container.Create(info); err != nil {
+			logger.Error("Create container error:", err)
+			return err
+		}
+		fmt.Println(info.Id)
+		return nil
+	},
+}
+
+// maxHostnameLength 主机名和域名的最大长度，与内核 UTS namespace 的限制一致
+const maxHostnameLength = 64
+
+// parseContainerInfo 解析 run/create 的命令行参数，生成容器的运行配置
+func parseContainerInfo(ctx *cli.Context, command string) (*models.Info, error) {
+	// 获取命令参数列表
+	args := ctx.Args()
+	logger.Debug("args:", args)
+	// 命令行参数校验
+	if len(args) < 2 {
+		return nil, fmt.Errorf("Usage: tinydocker %s [OPTIONS] IMAGE COMMAND", command)
+	}
+	name := ctx.String("name")
+	enableTTY := ctx.Bool("it")
+	detach := ctx.Bool("d")
+
+	if enableTTY && detach {
+		logger.Error("-it and -d cannot be used together")
+		return nil, errors.New("-it and -d cannot be used together")
+	}
+
+	memoryLimit := ctx.String("m")
+	cpuLimit := ctx.String("cpus")
+	volume := ctx.String("v")
+	// --env-file 先于 -e 生效，-e 可以覆盖文件中的同名变量
+	var envVars []string
+	for _, envFile := range ctx.StringSlice("env-file") {
+		fileEnv, err := container.ReadEnvFile(envFile)
+		if err != nil {
+			return nil, err
+		}
+		envVars = append(envVars, fileEnv...)
+	}
+	cliEnv, err := container.ParseEnv(ctx.StringSlice("e"))
+	if err != nil {
+		return nil, err
+	}
+	envVars = append(envVars, cliEnv...)
+	imageName := ctx.Args().Get(0)
+	network := ctx.String("net")
+	portMapping := ctx.StringSlice("p")
+	restartPolicy, err := container.ParseRestartPolicy(ctx.String("restart"))
+	if err != nil {
+		return nil, err
+	}
+	autoRemove := ctx.Bool("rm")
+	if autoRemove && restartPolicy.Name != models.RestartPolicyNo {
+		return nil, errors.New("--rm and --restart cannot be used together")
+	}
+	ulimits := ctx.StringSlice("ulimit")
+	if _, err := container.ParseUlimits(ulimits); err != nil {
+		return nil, err
+	}
+	stopSignal := ctx.String("stop-signal")
+	if _, err := container.ParseSignal(stopSignal); err != nil {
+		return nil, err
+	}
+	workingDir := ctx.String("workdir")
+	if workingDir != "" && !filepath.IsAbs(workingDir) {
+		return nil, fmt.Errorf("workdir %q must be an absolute path", workingDir)
+	}
+	hostname := ctx.String("hostname")
+	if len(hostname) > maxHostnameLength {
+		return nil, fmt.Errorf("hostname %q is longer than %d characters", hostname, maxHostnameLength)
+	}
+	domainname := ctx.String("domainname")
+	if len(domainname) > maxHostnameLength {
+		return nil, fmt.Errorf("domainname %q is longer than %d characters", domainname, maxHostnameLength)
+	}
+	var hooks *oci.Hooks
+	if hooksFile := ctx.String("hooks"); hooksFile != "" {
+		if hooks, err = container.ReadHooksFile(hooksFile); err != nil {
+			return nil, err
+		}
+	}
+	labels, err := filters.ParseLabels(ctx.StringSlice("label"))
+	if err != nil {
+		return nil, err
+	}
+	healthcheck, err := container.NewHealthConfig(ctx.String("health-cmd"), ctx.Duration("health-interval"),
+		ctx.Duration("health-timeout"), ctx.Duration("health-start-period"), ctx.Int("health-retries"))
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("enableTTY:", enableTTY, "detach:", detach,
+		"memoryLimit:", memoryLimit, "cpuLimit:", cpuLimit, "volume:", volume, "image:", imageName, "envVars:", envVars)
+	return &models.Info{
+		Name:          name,
+		Image:         imageName,
+		Args:          args[1:],
+		Env:           envVars,
+		Volume:        volume,
+		MemoryLimit:   memoryLimit,
+		CpuLimit:      cpuLimit,
+		PidsLimit:     ctx.Int64("pids-limit"),
+		TTY:           enableTTY,
+		Detach:        detach,
+		Network:       network,
+		PortMapping:   portMapping,
+		RestartPolicy: restartPolicy,
+		StopSignal:    stopSignal,
+		Ulimits:       ulimits,
+		WorkingDir:    workingDir,
+		User:          ctx.String("user"),
+		Hostname:      hostname,
+		Domainname:    domainname,
+		AutoRemove:    autoRemove,
+		Init:          ctx.Bool("init"),
+		Hooks:         hooks,
+		Healthcheck:   healthcheck,
+		Labels:        labels,
+	}, nil
+}
+
 // docker export imageName
 var ExportCommand = cli.Command{
 	Name:  "export",
@@ -129,12 +338,131 @@ var ExportCommand = cli.Command{
 	},
 }
 
+// docker import <tarfile> <image>
+var ImportCommand = cli.Command{
+	Name:      "import",
+	Usage:     "Import a root filesystem tar file as an image",
+	ArgsUsage: "<tarfile> <image>",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "label, l",
+			Usage: "Set metadata on the image (e.g., --label version=1.0)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "env, e",
+			Usage: "Set default environment variables of the image (e.g., --env KEY=VALUE)",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if len(ctx.Args()) < 2 {
+			return errors.New("Usage: tinydocker import [--label k=v] [--env KEY=VALUE] <tarfile> <image>")
+		}
+		labels, err := filters.ParseLabels(ctx.StringSlice("label"))
+		if err != nil {
+			return err
+		}
+		return image.Import(ctx.Args().Get(0), ctx.Args().Get(1), labels, ctx.StringSlice("env"))
+	},
+}
+
+// docker images
+var ImagesCommand = cli.Command{
+	Name:  "images",
+	Usage: "List images",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "filter, f",
+			Usage: "Filter output based on conditions provided (e.g., label=version=1.0, reference=busybox)",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		args, err := filters.Parse(ctx.StringSlice("filter"), image.FilterKeys...)
+		if err != nil {
+			return err
+		}
+		images, err := image.List(args)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+		fmt.Fprint(w, "IMAGE\tSIZE\tCREATED\n")
+		for _, img := range images {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", img.Name, img.Size, img.CreatedAt)
+		}
+		return w.Flush()
+	},
+}
+
 // docker ps
 var PsCommand = cli.Command{
 	Name:  "ps",
 	Usage: "List containers",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "Show all containers (default shows just running)",
+		},
+		&cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "Only display container IDs",
+		},
+		&cli.BoolFlag{
+			Name:  "no-trunc",
+			Usage: "Don't truncate output",
+		},
+		&cli.StringSliceFlag{
+			Name:  "filter, f",
+			Usage: "Filter output based on conditions provided (status, name, label, network, ancestor; e.g., status=exited; status is one of created, running, paused, restarting, stopped or exited)",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format output using a Go template (e.g., '{{.Id}} {{.Name}}') or 'json'",
+		},
+	},
 	Action: func(ctx *cli.Context) error {
-		return models.PrintContainersInfo()
+		args, err := models.ParseContainerFilters(ctx.StringSlice("filter"))
+		if err != nil {
+			return err
+		}
+		containers := models.FilterContainers(models.ReadContainersInfoByLabels(args.Get("label")), ctx.Bool("all"), args)
+		noTrunc := ctx.Bool("no-trunc")
+
+		if ctx.Bool("quiet") {
+			for _, info := range containers {
+				if noTrunc {
+					fmt.Println(info.Id)
+				} else {
+					fmt.Println(models.TruncateID(info.Id))
+				}
+			}
+			return nil
+		}
+
+		switch format := ctx.String("format"); format {
+		case "":
+			return models.PrintContainersInfo(containers, noTrunc)
+		case "json":
+			// 每行一个 JSON 对象，便于脚本逐行处理
+			for _, info := range containers {
+				data, err := json.Marshal(info)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			}
+		default:
+			tmpl, err := parseFormat(format)
+			if err != nil {
+				return fmt.Errorf("invalid format: %v", err)
+			}
+			for _, info := range containers {
+				if err := tmpl.Execute(os.Stdout, info); err != nil {
+					return err
+				}
+				fmt.Println()
+			}
+		}
+		return nil
 	},
 }
 
@@ -190,30 +518,135 @@ var ExecContainerCommand = cli.Command{
 	},
 }
 
-// docker stop <containerNameOrID>
+// docker stop <containerNameOrID>...
 var StopCommand = cli.Command{
 	Name:  "stop",
 	Usage: "Stop one or more running containers",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "time, t",
+			Usage: "Seconds to wait for stop before killing it",
+			Value: int(container.DefaultStopTimeout / time.Second),
+		},
+	},
 	Action: func(ctx *cli.Context) error {
 		if ctx.NArg() == 0 {
 			return errors.New("at least one container name or ID must be specified")
 		}
 
-		name := ctx.Args().Get(0)
-		if len(name) == 0 {
-			return errors.New("container name cannot be empty")
+		timeout := time.Duration(ctx.Int("time")) * time.Second
+		return forEachContainer(ctx, "stop", func(name string) error {
+			return container.Stop(name, timeout)
+		})
+	},
+}
+
+// docker start <containerNameOrID>...
+var StartCommand = cli.Command{
+	Name:  "start",
+	Usage: "Start one or more stopped containers",
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("at least one container name or ID must be specified")
 		}
 
-		if err := container.Stop(name); err != nil {
-			logger.Error("Failed to stop container %s: %v", name, err)
-			return err
+		return forEachContainer(ctx, "start", container.Start)
+	},
+}
+
+// docker restart <containerNameOrID>...
+var RestartCommand = cli.Command{
+	Name:  "restart",
+	Usage: "Restart one or more containers",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "time, t",
+			Usage: "Seconds to wait for stop before killing the container",
+			Value: int(container.DefaultStopTimeout / time.Second),
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("at least one container name or ID must be specified")
+		}
+
+		timeout := time.Duration(ctx.Int("time")) * time.Second
+		return forEachContainer(ctx, "restart", func(name string) error {
+			return container.Restart(name, timeout)
+		})
+	},
+}
+
+// docker pause <containerNameOrID>...
+var PauseCommand = cli.Command{
+	Name:  "pause",
+	Usage: "Pause all processes within one or more containers",
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("at least one container name or ID must be specified")
+		}
+		return forEachContainer(ctx, "pause", container.Pause)
+	},
+}
+
+// docker unpause <containerNameOrID>...
+var UnpauseCommand = cli.Command{
+	Name:  "unpause",
+	Usage: "Unpause all processes within one or more containers",
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("at least one container name or ID must be specified")
 		}
+		return forEachContainer(ctx, "unpause", container.Unpause)
+	},
+}
 
+// docker wait <containerNameOrID>
+var WaitCommand = cli.Command{
+	Name:  "wait",
+	Usage: "Block until a container stops, then print its exit code",
+	Action: func(ctx *cli.Context) error {
+		name := ctx.Args().First()
+		if name == "" {
+			return errors.New("container name cannot be empty")
+		}
+		exitCode, err := container.Wait(name)
+		if err != nil {
+			logger.Error("Failed to wait container %s: %v", name, err)
+			return err
+		}
+		fmt.Println(exitCode)
 		return nil
 	},
 }
 
-// docker rm [-f] <containerNameOrID>
+// docker kill [-s SIGNAL] <containerNameOrID>...
+var KillCommand = cli.Command{
+	Name:  "kill",
+	Usage: "Send a signal to the init process of one or more running containers",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "signal, s",
+			Usage: "Signal to send to the container",
+			Value: "SIGKILL",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("at least one container name or ID must be specified")
+		}
+
+		sig, err := container.ParseSignal(ctx.String("signal"))
+		if err != nil {
+			return err
+		}
+		return forEachContainer(ctx, "kill", func(name string) error {
+			return container.Kill(name, sig)
+		})
+	},
+}
+
+// docker rm [-f] <containerNameOrID>...
 var RemoveCommand = cli.Command{
 	Name:  "rm",
 	Usage: "Remove one or more containers",
@@ -228,17 +661,85 @@ var RemoveCommand = cli.Command{
 			return errors.New("at least one container name or ID must be specified")
 		}
 
-		name := ctx.Args().Get(0)
-		if len(name) == 0 {
-			return errors.New("container name cannot be empty")
-		}
-
 		force := ctx.Bool("f")
-		if err := container.Remove(name, force); err != nil {
-			logger.Error("Failed to remove container %s: %v", name, err)
+		return forEachContainer(ctx, "remove", func(name string) error {
+			return container.Remove(name, force)
+		})
+	},
+}
+
+// docker rename <containerNameOrID> <newName>
+var RenameCommand = cli.Command{
+	Name:      "rename",
+	Usage:     "Rename a container",
+	ArgsUsage: "<container> <new-name>",
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return errors.New("Usage: tinydocker rename <container> <new-name>")
+		}
+		name, newName := ctx.Args().Get(0), ctx.Args().Get(1)
+		if err := container.Rename(name, newName); err != nil {
+			logger.Error("Failed to rename container %s: %v", name, err)
 			return err
 		}
+		return nil
+	},
+}
 
+// docker update [--memory] [--cpus] [--pids-limit] <containerNameOrID>
+var UpdateCommand = cli.Command{
+	Name:      "update",
+	Usage:     "Update resource limits of a container",
+	ArgsUsage: "<container>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "memory, m",
+			Usage: "Memory limit (e.g., 100m, max for unlimited)",
+		},
+		&cli.StringFlag{
+			Name:  "cpus",
+			Usage: "CPU limit (e.g., 1.5)",
+		},
+		&cli.Int64Flag{
+			Name:  "pids-limit",
+			Usage: "Maximum number of processes in the container (0 or -1 for unlimited)",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		name := ctx.Args().First()
+		if name == "" {
+			return errors.New("container name cannot be empty")
+		}
+		config := &container.UpdateConfig{
+			MemoryLimit: ctx.String("memory"),
+			CpuLimit:    ctx.String("cpus"),
+		}
+		if ctx.IsSet("pids-limit") {
+			pidsLimit := ctx.Int64("pids-limit")
+			config.PidsLimit = &pidsLimit
+		}
+		if err := container.Update(name, config); err != nil {
+			logger.Error("Failed to update container %s: %v", name, err)
+			return err
+		}
 		return nil
 	},
 }
+
+// forEachContainer 对命令的每个容器参数依次执行 fn，成功时输出该参数，
+// 某个容器失败不影响其他容器，全部执行完后返回失败的容器
+func forEachContainer(ctx *cli.Context, action string, fn func(name string) error) error {
+	var failed []string
+	for _, name := range ctx.Args() {
+		if err := fn(name); err != nil {
+			logger.Error("Failed to %s container %s: %v", action, name, err)
+			failed = append(failed, name)
+			continue
+		}
+		fmt.Println(name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to %s containers: %s", action, strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+)
+
+// formatFuncs --format 模板中可用的函数，例如 {{json .Labels}}、{{join .Args " "}}
+var formatFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// parseFormat 解析 --format 指定的 Go 模板
+func parseFormat(format string) (*template.Template, error) {
+	return template.New("format").Funcs(formatFuncs).Parse(format)
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/phper95/tinydocker/container"
+	"github.com/phper95/tinydocker/image"
+	"github.com/phper95/tinydocker/network"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"github.com/urfave/cli"
+)
+
+// inspect 支持的对象类型，未指定 --type 时按该顺序查找
+const (
+	inspectTypeContainer = "container"
+	inspectTypeNetwork   = "network"
+	inspectTypeImage     = "image"
+	// 数据卷是 -v 挂载的宿主机目录，按路径查找
+	inspectTypeVolume = "volume"
+)
+
+var inspectTypes = []string{inspectTypeContainer, inspectTypeNetwork, inspectTypeImage, inspectTypeVolume}
+
+// docker inspect [--type] [--format] <obj>...
+var InspectCommand = cli.Command{
+	Name:      "inspect",
+	Usage:     "Display detailed information on containers, networks, images or volumes",
+	ArgsUsage: "<name|id>...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "Only inspect objects of the given type (container, network, image, volume)",
+		},
+		&cli.StringFlag{
+			Name:  "format, f",
+			Usage: "Format the output using the given Go template (e.g., '{{.Endpoint.IPAddress}}')",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("at least one object name or ID must be specified")
+		}
+		types := inspectTypes
+		if t := ctx.String("type"); t != "" {
+			if !containsString(inspectTypes, t) {
+				return fmt.Errorf("invalid type %q, must be one of %s", t, strings.Join(inspectTypes, ", "))
+			}
+			types = []string{t}
+		}
+
+		var objects []interface{}
+		var missing []string
+		for _, name := range ctx.Args() {
+			obj, err := inspectObject(name, types)
+			if err != nil {
+				logger.Error("inspect %s error: %v", name, err)
+				missing = append(missing, name)
+				continue
+			}
+			objects = append(objects, obj)
+		}
+
+		if format := ctx.String("format"); format != "" {
+			tmpl, err := parseFormat(format)
+			if err != nil {
+				return fmt.Errorf("invalid format: %v", err)
+			}
+			for _, obj := range objects {
+				if err := tmpl.Execute(os.Stdout, obj); err != nil {
+					return err
+				}
+				fmt.Println()
+			}
+		} else if len(objects) > 0 {
+			data, err := json.MarshalIndent(objects, "", "    ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+
+		if len(missing) > 0 {
+			return fmt.Errorf("no such object: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	},
+}
+
+// inspectObject 按类型顺序查找名称对应的对象
+func inspectObject(name string, types []string) (interface{}, error) {
+	var lastErr error
+	for _, t := range types {
+		var obj interface{}
+		var err error
+		switch t {
+		case inspectTypeContainer:
+			obj, err = container.Inspect(name)
+		case inspectTypeNetwork:
+			obj, err = network.Inspect(name)
+		case inspectTypeImage:
+			obj, err = image.Inspect(name)
+		case inspectTypeVolume:
+			obj, err = container.InspectVolume(name)
+		}
+		if err == nil {
+			return obj, nil
+		}
+		// 前缀匹配到多个容器时不再按其他类型查找
+		if errors.Is(err, container.ErrAmbiguousContainer) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// containsString 判断 values 中是否包含 value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"errors"
 	"github.com/phper95/tinydocker/network"
+	"github.com/phper95/tinydocker/pkg/filters"
 	"github.com/urfave/cli"
 )
 
@@ -26,6 +27,10 @@ var NetworkCommand = cli.Command{
 					Usage: "Network driver (e.g., bridge)",
 					Value: "bridge",
 				},
+				&cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "Set metadata on a network (e.g., --label team=infra)",
+				},
 			},
 			Action: func(ctx *cli.Context) error {
 				name := ctx.Args().First()
@@ -37,14 +42,28 @@ var NetworkCommand = cli.Command{
 					return errors.New("--subnet is required")
 				}
 				driver := ctx.String("driver")
-				return network.CreateNetwork(name, driver, subnet)
+				labels, err := filters.ParseLabels(ctx.StringSlice("label"))
+				if err != nil {
+					return err
+				}
+				return network.CreateNetwork(name, driver, subnet, labels)
 			},
 		},
 		{
 			Name:  "ls",
 			Usage: "List container network",
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "filter, f",
+					Usage: "Filter output based on conditions provided (e.g., label=team=infra, name=mynet, driver=bridge)",
+				},
+			},
 			Action: func(ctx *cli.Context) error {
-				network.ListNetwork()
+				args, err := filters.Parse(ctx.StringSlice("filter"), network.NetworkFilterKeys...)
+				if err != nil {
+					return err
+				}
+				network.ListNetwork(args)
 				return nil
 			},
 		},
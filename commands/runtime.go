@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/phper95/tinydocker/container"
+	"github.com/urfave/cli"
+	"syscall"
+)
+
+// OCI runtime 命令行接口，参考 opencontainers/runtime-tools 中的 runtime 命令约定
+// tinydocker runtime create --bundle <dir> <id>
+// tinydocker runtime start <id>
+// tinydocker runtime state <id>
+// tinydocker runtime kill <id> [signal]
+// tinydocker runtime delete [--force] <id>
+var RuntimeCommand = cli.Command{
+	Name:  "runtime",
+	Usage: "Run OCI bundles following the opencontainers runtime-spec",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "Create a container from an OCI bundle",
+			ArgsUsage: "<id>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "bundle, b",
+					Usage: "Path to the OCI bundle directory",
+					Value: ".",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				id, err := runtimeContainerID(ctx)
+				if err != nil {
+					return err
+				}
+				return container.CreateFromBundle(id, ctx.String("bundle"))
+			},
+		},
+		{
+			Name:      "start",
+			Usage:     "Run the user process of a created container",
+			ArgsUsage: "<id>",
+			Action: func(ctx *cli.Context) error {
+				id, err := runtimeContainerID(ctx)
+				if err != nil {
+					return err
+				}
+				return container.RuntimeStart(id)
+			},
+		},
+		{
+			Name:      "state",
+			Usage:     "Output the state of a container in OCI format",
+			ArgsUsage: "<id>",
+			Action: func(ctx *cli.Context) error {
+				id, err := runtimeContainerID(ctx)
+				if err != nil {
+					return err
+				}
+				state, err := container.State(id)
+				if err != nil {
+					return err
+				}
+				data, err := json.MarshalIndent(state, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			},
+		},
+		{
+			Name:      "kill",
+			Usage:     "Send a signal to the init process of a container",
+			ArgsUsage: "<id> [signal]",
+			Action: func(ctx *cli.Context) error {
+				id, err := runtimeContainerID(ctx)
+				if err != nil {
+					return err
+				}
+				sig := syscall.SIGTERM
+				if s := ctx.Args().Get(1); s != "" {
+					if sig, err = container.ParseSignal(s); err != nil {
+						return err
+					}
+				}
+				return container.RuntimeKill(id, sig)
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a container and release its resources",
+			ArgsUsage: "<id>",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "Force delete a running container",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				id, err := runtimeContainerID(ctx)
+				if err != nil {
+					return err
+				}
+				return container.RuntimeDelete(id, ctx.Bool("force"))
+			},
+		},
+	},
+}
+
+// runtimeContainerID 获取 runtime 子命令的容器ID参数
+func runtimeContainerID(ctx *cli.Context) (string, error) {
+	id := ctx.Args().First()
+	if id == "" {
+		return "", errors.New("container id is required")
+	}
+	return id, nil
+}
@@ -1,14 +1,16 @@
 package container
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/phper95/tinydocker/container/models"
 	"github.com/phper95/tinydocker/network"
-	"github.com/phper95/tinydocker/pkg/db"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,7 +18,6 @@ import (
 	"github.com/phper95/tinydocker/enum"
 	"github.com/phper95/tinydocker/filesys"
 	"github.com/phper95/tinydocker/pkg/logger"
-	"github.com/urfave/cli"
 )
 
 // Paths used across container lifecycle for overlayfs and volume handling.
@@ -24,75 +25,395 @@ const (
 	BusyboxRoot = "/var/local/busybox"
 )
 
-func Run(args cli.Args, name string, enableTTY bool, detach bool,
-	memoryLimit, cpuLimit, volume string, imageName string, envVars []string, net string, portMapping []string) error {
-	logger.Debug("Run  args: ", args)
+// DefaultStopTimeout stop/restart 时等待容器进程退出的最长时间
+const DefaultStopTimeout = 10 * time.Second
 
-	// initCmdArgs := []string{"init"}
-	// 将Run命令的参数传递给init命令
-	// if len(initCmdArgs) > 0 {
-	// 	initCmdArgs = append(initCmdArgs, args...)
-	// }
+// Run 创建并启动一个新容器，info 中保存了完整的运行参数
+func Run(info *models.Info) error {
+	logger.Debug("Run  args: ", info.Args)
 
-	info := models.Info{
-		Name:      name,
-		Id:        models.GenerateRandomContainerID(),
-		Command:   strings.Join(args, " "),
-		State:     enum.ContainerStateRunning,
-		StartedAt: time.Now().Format(time.DateTime),
-		Image:     imageName,
+	if err := Create(info); err != nil {
+		return err
+	}
+	unlock, err := models.LockContainer(info.Id)
+	if err != nil {
+		return err
 	}
+	return startContainer(info, sync.OnceFunc(unlock))
+}
 
-	initCmd, write, err := NewInitProcess(enableTTY, memoryLimit, cpuLimit, volume, &info, envVars)
+// Create 准备容器目录、根文件系统、容器记录并分配网络IP，但不启动用户进程，
+// 容器处于 created 状态，之后通过 Start 启动
+func Create(info *models.Info) error {
+	// 名称检查到写入容器记录之间持有全局锁，同名的并发创建只有一个成功
+	unlock, err := models.LockContainers()
 	if err != nil {
-		logger.Error("Failed to create init process error: ", err)
 		return err
 	}
+	defer unlock()
+
+	// 名称在创建时必须唯一，未指定时自动生成
+	if info.Name == "" {
+		info.Name = generateContainerName()
+	} else if err := checkNameAvailable(info.Name); err != nil {
+		return err
+	}
+	info.Id = models.GenerateRandomContainerID()
+	info.Command = strings.Join(info.Args, " ")
+	info.State = models.ContainerStateCreated
+	info.CreatedAt = time.Now().Format(time.DateTime)
+	if info.Hostname == "" {
+		info.Hostname = info.Id[:12]
+	}
+
+	// 容器的环境变量只来自镜像默认值和用户指定的变量，记录最终结果供 inspect 查看
+	imageConfig, err := models.ReadImageConfig(info.Image)
+	if err != nil {
+		return err
+	}
+	info.Env = BuildEnv(info.Hostname, info.TTY, imageConfig.Env, info.Env)
+
+	if err := prepareRootfs(info); err != nil {
+		cleanup(info)
+		return err
+	}
+
+	if info.Network != "" {
+		ip, err := network.AllocateContainerIP(info.Network)
+		if err != nil {
+			logger.Error("Failed to allocate ip for container error: ", err)
+			cleanup(info)
+			return err
+		}
+		info.IpAddress = ip.String()
+	}
+
+	if err := models.WriteContainerInfo(info); err != nil {
+		logger.Error("Failed to write container info error: ", err)
+		// 记录没有写入，cleanup 无法从记录中认领IP，先直接归还
+		if info.IpAddress != "" {
+			if err := network.Disconnect(info); err != nil {
+				logger.Error("Failed to release container ip error: ", err)
+			}
+		}
+		cleanup(info)
+		return err
+	}
+	logger.Info("Container %s created", info.Id)
+	return nil
+}
+
+// Start 根据容器记录中保存的运行参数重新启动一个已停止的容器
+func Start(containerName string) error {
+	info, unlock, err := lockContainer(containerName)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerName, err)
+	}
+	return start(containerName, info, sync.OnceFunc(unlock))
+}
+
+// start 启动容器，调用前必须持有容器锁，容器启动完成或失败后通过 unlock 释放
+func start(containerName string, info *models.Info, unlock func()) error {
+	defer unlock()
+	if info.State == models.ContainerStateRunning || info.State == models.ContainerStateRestarting ||
+		info.State == models.ContainerStatePaused {
+		return fmt.Errorf("container %s is already running", containerName)
+	}
+	if len(info.Args) == 0 {
+		return fmt.Errorf("container %s has no saved command, please recreate it", containerName)
+	}
+	// OCI bundle 容器的 init 进程在 create 时已经启动，start 只需让它执行用户进程
+	if info.Bundle != "" {
+		return startBundleContainer(info)
+	}
+
+	info.RestartCount = 0
+	info.ManuallyStopped = false
+
+	// 后台容器退出时 CLI 进程可能已经不存在，清理上次运行残留的挂载和cgroup
+	if info.State != models.ContainerStateCreated {
+		cleanup(info)
+	}
+	return startContainer(info, unlock)
+}
+
+// Restart 停止正在运行的容器并使用保存的配置重新启动，timeout 为等待容器退出的时间
+func Restart(containerName string, timeout time.Duration) error {
+	info, unlock, err := lockContainer(containerName)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerName, err)
+	}
+	unlock = sync.OnceFunc(unlock)
+	defer unlock()
+	if info.State == models.ContainerStateRunning || info.State == models.ContainerStatePaused ||
+		info.State == models.ContainerStateRestarting {
+		if err := stop(containerName, info, timeout); err != nil {
+			return err
+		}
+		// 重新读取停止后的配置
+		if info, err = models.GetContainerInfo(info.Id); err != nil {
+			return err
+		}
+	}
+	return start(containerName, info, unlock)
+}
+
+// startContainer 启动容器：后台容器交给独立的监控进程托管，前台容器由当前进程托管。
+// 调用前持有容器锁，容器启动完成后调用 unlock，前台容器运行期间不持有锁
+func startContainer(info *models.Info, unlock func()) error {
+	defer unlock()
+	if info.Detach {
+		return spawnShim(info)
+	}
+
+	// 交互式容器使用 CLI 所在的终端，容器退出后恢复终端设置
+	var tty *console
+	if info.TTY {
+		var err error
+		if tty, err = newConsole(); err != nil {
+			return err
+		}
+		defer tty.Close()
+	}
+	initCmd, err := launchContainer(info, tty)
+	if err != nil {
+		launchFailed(info, err)
+		return err
+	}
+	unlock()
+	// 前台容器由 CLI 托管，CLI 收到的终止信号转发给容器而不是直接退出
+	stopForward := forwardSignals(info.Id)
+	defer stopForward()
+	// 等待/托管容器进程
+	return monitor(info, initCmd, tty)
+}
+
+// launchFailed 容器启动失败时清理已经创建的资源，使用 --rm 的容器直接删除，其他容器记录为已停止并保存错误信息。
+// 配置了网络的容器在失败前可能已经写入 running 状态，需要改写
+func launchFailed(info *models.Info, launchErr error) {
+	if info.AutoRemove {
+		if err := removeContainer(info); err != nil {
+			logger.Error("Failed to remove container %s: %v", info.Id, err)
+		}
+		return
+	}
+	cleanup(info)
+	if _, err := recordExit(info.Id, exitResult{Code: -1, Error: launchErr.Error()}, models.ContainerStateStopped); err != nil {
+		logger.Error("Failed to record container exit error: ", err)
+	}
+}
+
+// launchContainer 挂载根文件系统，启动容器init进程并配置网络，返回运行中的init进程。
+// 交互式容器的 init 进程使用 tty 新分配的 pty，其他容器 tty 为 nil
+func launchContainer(info *models.Info, tty *console) (*exec.Cmd, error) {
+	// 已停止的容器需要重新挂载根文件系统，created 状态的容器在 Create 时已经挂载
+	if info.Bundle == "" && !filesys.IsMounted(GetContainerMountPoint(info.Id)) {
+		if err := prepareRootfs(info); err != nil {
+			return nil, err
+		}
+	}
+
+	info.State = enum.ContainerStateRunning
+	if info.Bundle != "" {
+		// OCI bundle 容器的 init 进程阻塞在 exec.fifo 上，runtime start 之后才进入 running
+		info.State = models.ContainerStateCreated
+	}
+	info.StartedAt = time.Now().Format(time.DateTime)
+	info.FinishedAt = ""
+	info.ExitCode = 0
+	info.ExitSignal = ""
+	info.OOMKilled = false
+	info.Error = ""
+	info.Health = nil
+	if info.Healthcheck != nil {
+		info.Health = &models.Health{Status: models.HealthStarting}
+	}
+
+	spec, err := NewInitSpec(info)
+	if err != nil {
+		return nil, err
+	}
+
+	initCmd, write, err := NewInitProcess(info, tty)
+	if err != nil {
+		logger.Error("Failed to create init process error: ", err)
+		return nil, err
+	}
 	logger.Debug("Container process started with pid: ", initCmd.Process.Pid)
 	// Update the PID after the process is started
 	info.Pid = initCmd.Process.Pid
+	if startTime, err := models.ReadProcessStartTime(info.Pid); err == nil {
+		info.PidStartTime = startTime
+	}
+	// 当前进程负责托管容器，记录下来，监控进程异常退出后可以据此校正 restarting 状态
+	info.MonitorPid = os.Getpid()
+	if startTime, err := models.ReadProcessStartTime(info.MonitorPid); err == nil {
+		info.MonitorStartTime = startTime
+	}
 
-	if net != "" {
-		info.Network = net
-		info.PortMapping = portMapping // 端口映射信息，用于容器间通信
-		ip, err := network.Connect(net, &info)
+	if info.Network != "" {
+		ip, err := network.Connect(info.Network, info)
 		if err != nil {
 			logger.Error("Failed to connect container to network error: ", err)
-			return err
+			killInitProcess(initCmd, write)
+			// 撤销已经创建的网络资源，包括 Connect 中新分配的IP
+			if err := network.Disconnect(info); err != nil {
+				logger.Error("Failed to disconnect container from network error: ", err)
+			}
+			// 记录中可能还保存着 Create 时分配的IP，已经归还，清空以免之后再次释放
+			if _, err := models.UpdateContainerInfo(info.Id, func(latest *models.Info) bool {
+				latest.Endpoint = nil
+				latest.IpAddress = ""
+				return true
+			}); err != nil {
+				logger.Error("Failed to update container info error: ", err)
+			}
+			return nil, err
 		}
 		info.IpAddress = ip.String()
+		// 先保存网络资源，进程异常退出后依然可以撤销
+		if err := models.WriteContainerInfo(info); err != nil {
+			logger.Error("Failed to write container info error: ", err)
+		}
+	}
+	// namespace 和网络已经就绪，用户进程启动前执行 prestart 钩子，失败时容器启动失败
+	if err := runHooks(info, HookPrestart); err != nil {
+		logger.Error("Failed to run prestart hooks error: ", err)
+		killInitProcess(initCmd, write)
+		return nil, err
 	}
-	// 在这里关闭数据库连接，防止其他容器启动时，无法获取数据库连接
-	db.GetBoltDBClient(db.DefaultBoltDBClientName).Close()
 
-	// 将管道写入端传递给init命令
-	err = SendInitCommand(args, write)
+	// 将启动规格通过管道发送给init进程
+	err = SendInitSpec(spec, write)
 	if err != nil {
-		logger.Error("Failed to send init command error: ", err)
-		return err
+		logger.Error("Failed to send init spec error: ", err)
+		killInitProcess(initCmd, write)
+		return nil, err
 	}
 	logger.Debug("Container info: ", info)
-	err = models.WriteContainerInfo(&info)
+	err = models.WriteContainerInfo(info)
 	if err != nil {
 		logger.Error("Failed to write container info error: ", err)
-		return err
+		killInitProcess(initCmd, write)
+		return nil, err
+	}
+	// OCI bundle 容器的用户进程在 runtime start 时才启动，poststart 钩子在那里执行
+	if info.Bundle == "" {
+		if err := runHooks(info, HookPoststart); err != nil {
+			logger.Warn("poststart hooks of container %s failed: %v", info.Id, err)
+		}
 	}
+	return initCmd, nil
+}
 
-	if detach { // 后台运行
-		go func() {
-			defer cleanup(volume, info.Id)
-			if err := initCmd.Wait(); err != nil {
-				logger.Warn("background container exited: %v", err)
-			}
-		}()
-		logger.Info("Container running in background with pid: %d", initCmd.Process.Pid)
-		return nil
+// monitor 等待容器init进程退出，将退出状态写入容器记录，
+// 根据重启策略决定重新拉起容器还是清理容器资源
+func monitor(info *models.Info, initCmd *exec.Cmd, tty *console) error {
+	backoff := restartBackoffMin
+	cgroupName := cgroups.ContainerCgroupName(info.Id)
+	for {
+		launchedAt := time.Now()
+		// cgroup 在自动重启时会复用，记录启动时的 OOM 次数用于判断本次退出是否由 OOM 导致
+		oomKills := cgroups.OOMKillCount(cgroupName)
+		stopHealthCheck := startHealthCheck(info)
+		waitErr := initCmd.Wait()
+		stopHealthCheck()
+		if tty != nil {
+			tty.drain()
+		}
+		result := newExitResult(initCmd.ProcessState)
+		result.OOMKilled = cgroups.OOMKillCount(cgroupName) > oomKills
+		// 重新读取配置，其他命令可能修改了重启策略或标记了手动停止
+		latest, err := models.GetContainerInfo(info.Id)
+		if err != nil {
+			logger.Error("Failed to read container info error: ", err)
+			teardown(info)
+			return waitErr
+		}
+		if !shouldRestart(latest, result.Code) {
+			finishContainer(latest, result)
+			return waitErr
+		}
+
+		backoff = restartBackoff(backoff, time.Since(launchedAt))
+		if _, err := recordExit(latest.Id, result, models.ContainerStateRestarting); err != nil {
+			logger.Error("Failed to record container exit error: ", err)
+		}
+		logger.Info("container %s exited with code %d, restarting in %v", latest.Id, result.Code, backoff)
+		time.Sleep(backoff)
+		backoff = nextRestartBackoff(backoff)
+
+		// 退避期间用户可能执行了 stop，重新读取配置
+		latest, err = models.GetContainerInfo(info.Id)
+		if err != nil {
+			teardown(info)
+			return waitErr
+		}
+		if latest.ManuallyStopped {
+			finishContainer(latest, result)
+			return waitErr
+		}
+		latest.RestartCount++
+		// 上次运行的网络资源需要先撤销，重新启动时会重新连接网络
+		releaseNetwork(latest)
+		initCmd, err = launchContainer(latest, tty)
+		if err != nil {
+			logger.Error("Failed to restart container error: ", err)
+			result.Error = err.Error()
+			finishContainer(latest, result)
+			return err
+		}
+		info = latest
 	}
+}
 
-	// 等待/托管容器进程
-	defer cleanup(volume, info.Id)
-	waitErr := initCmd.Wait()
-	return waitErr
+// finishContainer 清理不再运行的容器的资源，全部完成后才写入 stopped 状态：
+// stop、restart、rm 等命令等到 stopped 后才继续，不会与清理同时操作挂载点、cgroup 和网络
+func finishContainer(info *models.Info, result exitResult) {
+	// 使用 --rm 的容器记录会被删除，先单独保存退出码，之后 wait 依然可以读到
+	if info.AutoRemove {
+		if err := models.SaveRemovedExit(info.Id, result.Code); err != nil {
+			logger.Error("Failed to record container exit error: ", err)
+		}
+	}
+	teardown(info)
+	if info.AutoRemove {
+		return
+	}
+	if _, err := recordExit(info.Id, result, models.ContainerStateStopped); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error("Failed to record container exit error: ", err)
+	}
+}
+
+// exitResult 容器init进程的退出结果
+type exitResult struct {
+	Code      int    // 退出码，被信号终止时为 128+信号值
+	Signal    string // 终止进程的信号名称
+	OOMKilled bool   // 是否因超出内存限制被杀死
+	Error     string // 启动或运行容器时的错误信息
+}
+
+// newExitResult 从进程的退出状态中解析退出码和终止信号
+func newExitResult(state *os.ProcessState) exitResult {
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if ok && ws.Signaled() {
+		// 与 shell 的约定一致，被信号终止的进程退出码为 128+信号值
+		return exitResult{Code: 128 + int(ws.Signal()), Signal: SignalName(ws.Signal())}
+	}
+	return exitResult{Code: state.ExitCode()}
+}
+
+// recordExit 重新读取容器记录（其他命令可能已修改）并写入容器的退出状态，state 为 stopped 或等待重启时的 restarting
+func recordExit(containerID string, result exitResult, state string) (*models.Info, error) {
+	return models.UpdateContainerInfo(containerID, func(info *models.Info) bool {
+		info.State = state
+		info.FinishedAt = time.Now().Format(time.DateTime)
+		info.ExitCode = result.Code
+		info.ExitSignal = result.Signal
+		info.OOMKilled = result.OOMKilled
+		info.Error = result.Error
+		return true
+	})
 }
 
 // GetContainerMountPoint 根据容器ID获取挂载点路径
@@ -100,101 +421,194 @@ func GetContainerMountPoint(containerId string) string {
 	return filepath.Join(models.DefaultContainerInfoPath, containerId, "overlay")
 }
 
-// 资源清理封装
-func cleanup(volume string, containerId string) {
-	// 使用基于容器ID的挂载点
-	containerMountPoint := GetContainerMountPoint(containerId)
-	containerDir := filepath.Join(models.DefaultContainerInfoPath, containerId)
-	if err := filesys.UnmountVolume(volume, containerMountPoint); err != nil {
-		logger.Error("Failed to unmount volume: ", err)
+// teardown 在容器不再运行（也不会被自动重启）时清理资源并执行 poststop 钩子，
+// 使用 --rm 的容器随后删除容器目录
+func teardown(info *models.Info) {
+	cleanup(info)
+	if err := runHooks(info, HookPoststop); err != nil {
+		logger.Warn("poststop hooks of container %s failed: %v", info.Id, err)
+	}
+	if info.AutoRemove {
+		if err := removeContainerDir(info.Id); err != nil {
+			logger.Error("Failed to auto remove container %s: %v", info.Id, err)
+			return
+		}
+		logger.Info("Container %s removed", info.Id)
 	}
-	if err := filesys.UnmountOverlayFS(containerDir, containerMountPoint); err != nil {
-		logger.Error("Failed to unmount overlayfs: ", err)
+}
+
+// 资源清理封装，只卸载挂载点并删除cgroup，保留 upper 层以便容器再次启动。
+// 返回卸载失败的错误，删除容器目录前必须确认已经卸载
+func cleanup(info *models.Info) error {
+	var unmountErr error
+	// 使用基于容器ID的挂载点
+	containerMountPoint := GetContainerMountPoint(info.Id)
+	if filesys.IsMounted(containerMountPoint) {
+		if err := filesys.UnmountVolume(info.Volume, containerMountPoint); err != nil {
+			logger.Error("Failed to unmount volume: ", err)
+			unmountErr = errors.Join(unmountErr, err)
+		}
+		if err := filesys.UnmountOverlay(containerMountPoint); err != nil {
+			logger.Error("Failed to unmount overlayfs: ", err)
+			unmountErr = errors.Join(unmountErr, err)
+		}
 	}
 
-	// 删除挂载点目录
-	if err := os.RemoveAll(containerMountPoint); err != nil {
+	// 删除挂载点目录（只删除空目录，避免卸载失败时误删容器文件）
+	if err := os.Remove(containerMountPoint); err != nil && !os.IsNotExist(err) {
 		logger.Error("Failed to remove container mount point: ", err)
 	}
 
-	if err := cgroups.Cleanup(); err != nil {
+	if err := cgroups.Remove(cgroups.ContainerCgroupName(info.Id)); err != nil {
 		logger.Error("Failed to cleanup cgroup error: ", err)
 	}
-}
 
-func NewInitProcess(enableTTY bool, memoryLimit, cpuLimit, volume string, info *models.Info, envVars []string) (*exec.Cmd, *os.File, error) {
+	releaseNetwork(info)
+	return unmountErr
+}
 
-	read, write, err := os.Pipe()
+// releaseNetwork 撤销容器的端口映射、veth 设备并释放IP，只更新容器记录中的网络字段，
+// 避免覆盖其他命令写入的状态
+func releaseNetwork(info *models.Info) {
+	// 先在事务内认领并清空记录中的网络资源，再撤销认领到的部分。监控进程和 rm、start 等命令可能同时清理，
+	// 只有一方能认领到，不会重复释放已经分配给其他容器的IP
+	var claimed *models.Info
+	_, err := models.UpdateContainerInfo(info.Id, func(latest *models.Info) bool {
+		if latest.Endpoint == nil && latest.IpAddress == "" {
+			return false
+		}
+		claimed = &models.Info{
+			Id:        latest.Id,
+			Network:   latest.Network,
+			Endpoint:  latest.Endpoint,
+			IpAddress: latest.IpAddress,
+		}
+		latest.Endpoint = nil
+		latest.IpAddress = ""
+		return true
+	})
+	info.Endpoint = nil
+	info.IpAddress = ""
 	if err != nil {
-		logger.Error("Failed to create pipe error: ", err)
-		return nil, nil, err
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Failed to write container info error: ", err)
+		}
+		return
 	}
-
-	initCmd := exec.Command("/proc/self/exe", "init")
-	// - CLONE_NEWUTS 设置新的 UTS namespace（允许设置主机名）
-	// - CLONE_NEWPID 设置新的 PID namespace（容器内看到的是独立的进程ID）
-	// - CLONE_NEWNS 设置新的 Mount namespace（允许挂载/卸载文件系统而不影响宿主机）
-	// - CLONE_NEWIPC 设置新的 IPC namespace（隔离进程间通信）
-
-	initCmd.SysProcAttr = &syscall.SysProcAttr{
-		Cloneflags: syscall.CLONE_NEWUTS |
-			syscall.CLONE_NEWPID |
-			syscall.CLONE_NEWNS |
-			syscall.CLONE_NEWNET |
-			syscall.CLONE_NEWIPC,
+	if claimed == nil {
+		return
 	}
+	if err := network.Disconnect(claimed); err != nil {
+		logger.Error("Failed to disconnect container from network error: ", err)
+	}
+}
 
-	// 传入管道文件读取端句柄，外带此句柄去创建子进程
-	initCmd.ExtraFiles = []*os.File{read}
-	if len(envVars) > 0 {
-		initCmd.Env = append(os.Environ(), envVars...)
+// waitProcessExit 轮询等待进程退出，超时返回false
+func waitProcessExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
+	return false
+}
 
+// prepareRootfs 创建容器的 overlay 根文件系统并挂载数据卷
+func prepareRootfs(info *models.Info) error {
 	// 根据imageName确定tar包路径，如果未指定则使用默认的busybox-rootfs.tar
-	tarPath := filepath.Join("/var/local", info.Image+".tar")
+	tarPath := models.GetImageTarPath(info.Image)
 
 	// 创建基于容器ID的挂载点
 	containerMountPoint := GetContainerMountPoint(info.Id)
 	if err := os.MkdirAll(containerMountPoint, 0755); err != nil {
 		logger.Error("Failed to create container mount point: ", err)
-		return nil, nil, err
+		return err
 	}
 
 	// Create and mount overlayfs.
 	imageDir := filepath.Join(models.DefaultImagePath, info.Image)
 	containerDir := filepath.Join(models.DefaultContainerInfoPath, info.Id)
 	logger.Debug("imageDir: %s, containerDir: %s, containerMountPoint: %s, tarPath: %s", imageDir, containerDir, containerMountPoint, tarPath)
-	err = filesys.CreateOverlayFS(containerDir, imageDir, containerMountPoint, tarPath)
+	err := filesys.CreateOverlayFS(containerDir, imageDir, containerMountPoint, tarPath)
 	if err != nil {
 		logger.Error("Failed to create overlayfs error: ", err)
-		return nil, nil, err
+		return err
 	}
 
 	// Mount data volume if specified.
-	if err := filesys.MountVolume(volume, containerMountPoint); err != nil {
+	if err := filesys.MountVolume(info.Volume, containerMountPoint); err != nil {
 		logger.Error("Failed to mount volume: ", err)
+		return err
+	}
+	return nil
+}
+
+// NewInitProcess 在新的 namespace 中启动 init 进程并加入容器的 cgroup，
+// 调用前容器根文件系统必须已经通过 prepareRootfs 挂载完成
+func NewInitProcess(info *models.Info, tty *console) (*exec.Cmd, *os.File, error) {
+
+	read, write, err := os.Pipe()
+	if err != nil {
+		logger.Error("Failed to create pipe error: ", err)
 		return nil, nil, err
 	}
-	// 设置工作目录
-	initCmd.Dir = containerMountPoint
+	// 读取端只需要传给 init 进程，启动后父进程中的副本随之关闭，启动失败时也不会泄漏
+	defer read.Close()
+
+	initCmd := exec.Command("/proc/self/exe", "init")
+	// - CLONE_NEWUTS 设置新的 UTS namespace（允许设置主机名）
+	// - CLONE_NEWPID 设置新的 PID namespace（容器内看到的是独立的进程ID）
+	// - CLONE_NEWNS 设置新的 Mount namespace（允许挂载/卸载文件系统而不影响宿主机）
+	// - CLONE_NEWIPC 设置新的 IPC namespace（隔离进程间通信）
 
-	// 设置交互模式
-	if enableTTY {
-		initCmd.Stdout = os.Stdout
-		initCmd.Stderr = os.Stderr
-		initCmd.Stdin = os.Stdin
+	initCmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUTS |
+			syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNS |
+			syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWIPC,
+	}
+
+	// 传入管道文件读取端句柄，外带此句柄去创建子进程
+	initCmd.ExtraFiles = []*os.File{read}
+
+	// 设置工作目录，init进程会将其作为新的根目录
+	initCmd.Dir = containerRootfs(info)
+
+	// 设置交互模式：pty 的 slave 作为 init 进程的标准输入输出和控制终端，init 进程 exec 用户进程后保留
+	if info.TTY {
+		if tty == nil {
+			write.Close()
+			return initCmd, write, fmt.Errorf("container %s requires a terminal", info.Id)
+		}
+		slave, err := tty.openPty()
+		if err != nil {
+			logger.Error("Failed to allocate pty: ", err)
+			write.Close()
+			return initCmd, write, err
+		}
+		// init 进程启动后由它持有 slave，所有 slave 关闭后 master 才能读到结束
+		defer slave.Close()
+		initCmd.Stdin = slave
+		initCmd.Stdout = slave
+		initCmd.Stderr = slave
+		initCmd.SysProcAttr = ttyProcAttr(initCmd.SysProcAttr)
 	} else {
 		// For non-TTY mode, redirect stdout and stderr to log file
 		logDir := filepath.Join(models.DefaultContainerInfoPath, info.Id)
-		if err := os.MkdirAll(logDir, 0622); err != nil {
+		if err := os.MkdirAll(logDir, 0755); err != nil {
 			logger.Error("Failed to create log directory: ", err)
+			write.Close()
 			return initCmd, write, err
 		}
 
 		logFilePath := filepath.Join(logDir, DefaultContainerLogFileName)
-		logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0622)
+		logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
 			logger.Error("Failed to create log file: ", err)
+			write.Close()
 			return initCmd, write, err
 		}
 		defer logFile.Close()
@@ -207,35 +621,49 @@ func NewInitProcess(enableTTY bool, memoryLimit, cpuLimit, volume string, info *
 
 	if err := initCmd.Start(); err != nil {
 		logger.Error("Failed to start container process error: ", err)
+		write.Close()
 		return initCmd, write, err
 	}
 
+	// init 进程已经启动，之后配置失败时需要杀死它，否则它会一直阻塞在读取启动规格上
 	// 创建CGroup
-	cg := cgroups.NewCGroupManager(enum.AppName)
+	cg := cgroups.NewCGroupManager(cgroups.ContainerCgroupName(info.Id))
 	// 设置内存限制
-	if memoryLimit != "" {
-		err := cg.SetMemoryLimit(memoryLimit)
+	if info.MemoryLimit != "" {
+		err := cg.SetMemoryLimit(info.MemoryLimit)
 		if err != nil {
 			logger.Error("Failed to set memory limit error: ", err)
+			killInitProcess(initCmd, write)
 			return initCmd, write, err
 		}
 
 	}
 
 	// 设置CPU限制
-	if cpuLimit != "" {
-		err := cg.SetCPULimit(cpuLimit) // 限制CPU为50%
+	if info.CpuLimit != "" {
+		err := cg.SetCPULimit(info.CpuLimit) // 限制CPU为50%
 		if err != nil {
 			logger.Error("Failed to set cpu limit error: ", err)
+			killInitProcess(initCmd, write)
 			return initCmd, write, err
 		}
 
 	}
 
+	// 设置进程数限制
+	if info.PidsLimit > 0 {
+		if err := cg.SetPidsLimit(info.PidsLimit); err != nil {
+			logger.Error("Failed to set pids limit error: ", err)
+			killInitProcess(initCmd, write)
+			return initCmd, write, err
+		}
+	}
+
 	// 应用CGroup
 	err = cg.Apply(initCmd.Process.Pid)
 	if err != nil {
 		logger.Error("Failed to apply cgroup error: ", err)
+		killInitProcess(initCmd, write)
 		return initCmd, write, err
 	}
 
@@ -243,13 +671,23 @@ func NewInitProcess(enableTTY bool, memoryLimit, cpuLimit, volume string, info *
 	return initCmd, write, nil
 }
 
-func SendInitCommand(cmd []string, write *os.File) error {
-	logger.Debug("send init command: %v", cmd)
+// killInitProcess 容器启动失败时杀死已经启动的 init 进程并回收，关闭启动规格管道
+func killInitProcess(initCmd *exec.Cmd, write *os.File) {
+	write.Close()
+	initCmd.Process.Kill()
+	initCmd.Wait()
+}
+
+// SendInitSpec 将启动规格序列化为 JSON 写入管道，init 进程读到 EOF 后开始初始化
+func SendInitSpec(spec *InitSpec, write *os.File) error {
 	defer write.Close()
-	command := strings.Join(cmd, " ")
-	logger.Debug("command all is [ %s ]", command)
-	if _, err := write.WriteString(command); err != nil {
-		return fmt.Errorf("send init command [%s] error:%v", command, err)
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("marshal init spec error: %v", err)
+	}
+	logger.Debug("send init spec: %s", string(data))
+	if _, err := write.Write(data); err != nil {
+		return fmt.Errorf("send init spec %s error: %v", string(data), err)
 	}
 	return nil
 }
@@ -0,0 +1,88 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultPathEnv 容器默认的 PATH，与 docker 一致，不继承宿主机的 PATH
+const DefaultPathEnv = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// BuildEnv 生成容器进程的环境变量，不继承宿主机环境。按优先级从低到高依次为：
+// 默认的 PATH、HOSTNAME（交互模式下还有 TERM），镜像默认环境变量，--env-file，-e
+func BuildEnv(hostname string, tty bool, layers ...[]string) []string {
+	env := []string{DefaultPathEnv}
+	if hostname != "" {
+		env = append(env, "HOSTNAME="+hostname)
+	}
+	if tty {
+		env = append(env, "TERM=xterm")
+	}
+	for _, layer := range layers {
+		for _, e := range layer {
+			env = setEnv(env, e)
+		}
+	}
+	return env
+}
+
+// setEnv 设置 KEY=VALUE，已存在的同名变量会被覆盖并保持原来的位置
+func setEnv(env []string, kv string) []string {
+	key, _, _ := strings.Cut(kv, "=")
+	for i, e := range env {
+		if k, _, _ := strings.Cut(e, "="); k == key {
+			env[i] = kv
+			return env
+		}
+	}
+	return append(env, kv)
+}
+
+// ParseEnv 校验 -e 和 --env-file 中的环境变量，只有变量名时（-e KEY）使用当前进程中的值，
+// 当前进程没有设置该变量时忽略
+func ParseEnv(vars []string) ([]string, error) {
+	var env []string
+	for _, v := range vars {
+		key, _, hasValue := strings.Cut(v, "=")
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid environment variable %q", v)
+		}
+		if !hasValue {
+			value, ok := os.LookupEnv(key)
+			if !ok {
+				continue
+			}
+			v = key + "=" + value
+		}
+		env = append(env, v)
+	}
+	return env, nil
+}
+
+// ReadEnvFile 读取 --env-file 指定的文件，每行一个 KEY=VALUE，忽略空行和 # 开头的注释
+func ReadEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open env file %s error: %v", path, err)
+	}
+	defer f.Close()
+	var vars []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		vars = append(vars, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read env file %s error: %v", path, err)
+	}
+	env, err := ParseEnv(vars)
+	if err != nil {
+		return nil, fmt.Errorf("env file %s: %v", path, err)
+	}
+	return env, nil
+}
@@ -0,0 +1,117 @@
+package container
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+func TestBuildEnvDefaults(t *testing.T) {
+	if got, want := BuildEnv("", false), []string{DefaultPathEnv}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildEnv() = %q, want %q", got, want)
+	}
+	got := BuildEnv("c0ffee", true)
+	want := []string{DefaultPathEnv, "HOSTNAME=c0ffee", "TERM=xterm"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildEnv(tty) = %q, want %q", got, want)
+	}
+	// 不继承宿主机的环境变量
+	t.Setenv("TINYDOCKER_TEST_HOST_ONLY", "1")
+	for _, e := range BuildEnv("c0ffee", false) {
+		if e == "TINYDOCKER_TEST_HOST_ONLY=1" {
+			t.Errorf("BuildEnv inherited host env %q", e)
+		}
+	}
+}
+
+// 镜像默认值、--env-file、-e 依次覆盖，同名变量保持第一次出现的位置
+func TestBuildEnvPrecedence(t *testing.T) {
+	image := []string{"PATH=/opt/app/bin:/usr/bin", "LANG=C.UTF-8", "MODE=image", "DEBUG=0"}
+	envFile := []string{"MODE=file", "EXTRA=file"}
+	flags := []string{"DEBUG=1", "EXTRA=flag", "EMPTY="}
+
+	got := BuildEnv("c0ffee", false, image, envFile, flags)
+	want := []string{
+		"PATH=/opt/app/bin:/usr/bin",
+		"HOSTNAME=c0ffee",
+		"LANG=C.UTF-8",
+		"MODE=file",
+		"DEBUG=1",
+		"EXTRA=flag",
+		"EMPTY=",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildEnv = %q, want %q", got, want)
+	}
+	// 用户可以覆盖默认的 HOSTNAME
+	if got := BuildEnv("c0ffee", false, nil, nil, []string{"HOSTNAME=web"}); got[1] != "HOSTNAME=web" || len(got) != 2 {
+		t.Errorf("BuildEnv with HOSTNAME override = %q", got)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	t.Setenv("TINYDOCKER_TEST_FROM_HOST", "host-value")
+	path := filepath.Join(t.TempDir(), "app.env")
+	content := `# 注释
+A=1
+
+  B=padded  
+URL=postgres://u:p@db/app?x=1
+TINYDOCKER_TEST_FROM_HOST
+TINYDOCKER_TEST_UNSET
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("ReadEnvFile error: %v", err)
+	}
+	want := []string{"A=1", "B=padded", "URL=postgres://u:p@db/app?x=1", "TINYDOCKER_TEST_FROM_HOST=host-value"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadEnvFile = %q, want %q", got, want)
+	}
+
+	if _, err := ReadEnvFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("ReadEnvFile of a missing file succeeded, want error")
+	}
+	for _, line := range []string{"=value", "MY VAR=1"} {
+		bad := filepath.Join(t.TempDir(), "bad.env")
+		if err := os.WriteFile(bad, []byte(line+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadEnvFile(bad); err == nil {
+			t.Errorf("ReadEnvFile with line %q succeeded, want error", line)
+		}
+	}
+}
+
+// 与 run 的处理流程一致：--env-file 和 -e 合并为容器的 Env，再叠加到 image import --env 记录的镜像默认值之上
+func TestImageEnvOverriddenByRunFlags(t *testing.T) {
+	var imageConfig models.ImageConfig
+	if err := json.Unmarshal([]byte(`{"env":["APP_ENV=production","LOG_LEVEL=info","PORT=80"]}`), &imageConfig); err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(t.TempDir(), "run.env")
+	if err := os.WriteFile(envFile, []byte("LOG_LEVEL=debug\nPORT=8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileEnv, err := ReadEnvFile(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cliEnv, err := ParseEnv([]string{"PORT=9090"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := BuildEnv("c0ffee", false, imageConfig.Env, append(fileEnv, cliEnv...))
+	want := []string{DefaultPathEnv, "HOSTNAME=c0ffee", "APP_ENV=production", "LOG_LEVEL=debug", "PORT=9090"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildEnv = %q, want %q", got, want)
+	}
+}
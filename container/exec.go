@@ -1,12 +1,12 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"github.com/phper95/tinydocker/container/models"
 	"os"
 	"os/exec"
 	"strconv"
-	"strings"
 
 	"github.com/phper95/tinydocker/pkg/logger"
 )
@@ -17,45 +17,75 @@ const ExecTargetPidEnv = "TINYDOCKER_EXEC_PID"
 // It re-execs the current binary with the hidden command "exec-container",
 // passing the target container's init PID via env for the child to join namespaces.
 func Exec(name string, args []string, enableTTY bool) error {
-	info, err := GetContainerInfoByName(name)
+	info, err := ResolveContainer(name)
 	if err != nil {
 		logger.Error("get container info failed: %v", err)
 		return err
 	}
+	if info.State == models.ContainerStatePaused {
+		return fmt.Errorf("container %s is paused, unpause it first", name)
+	}
 	if info.Pid <= 0 {
 		logger.Error("container %s is not running (no pid)", name)
 		return fmt.Errorf("container %s is not running (no pid)", name)
 	}
 	logger.Debug("exec target pid:", info.Pid, "args:", args)
 
-	// prepare re-exec
-	cmd := exec.Command("/proc/self/exe", "exec-container")
-	cmd.Args = append(cmd.Args, args...)
-
-	// attach stdio (we don't allocate a pty here)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := execContainerCommand(context.Background(), info, args)
 
-	// pass env with target pid
-	env := os.Environ()
-	env = append(env, ExecTargetPidEnv+"="+strconv.Itoa(info.Pid))
-	cmd.Env = env
+	var tty *console
+	var slave *os.File
+	if enableTTY {
+		// 分配 pty 作为命令的控制终端，宿主机终端进入 raw 模式直到命令退出
+		if tty, err = newConsole(); err != nil {
+			return err
+		}
+		defer tty.Close()
+		if slave, err = tty.openPty(); err != nil {
+			return err
+		}
+		cmd.Stdin = slave
+		cmd.Stdout = slave
+		cmd.Stderr = slave
+		cmd.SysProcAttr = ttyProcAttr(cmd.SysProcAttr)
+	} else {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
 
-	if err := cmd.Run(); err != nil {
+	err = cmd.Start()
+	if slave != nil {
+		// 命令启动后由它持有 slave，所有 slave 关闭后 master 才能读到结束
+		slave.Close()
+	}
+	if err != nil {
+		logger.Error("exec in container %s failed: %v", name, err)
+		return fmt.Errorf("exec in container %s failed: %w", name, err)
+	}
+	err = cmd.Wait()
+	if tty != nil {
+		tty.drain()
+	}
+	if err != nil {
 		logger.Error("exec in container %s failed: %v", name, err)
 		return fmt.Errorf("exec in container %s failed: %w", name, err)
 	}
 	return nil
 }
 
-func GetContainerInfoByName(name string) (*models.Info, error) {
-	all := models.ReadContainersInfo()
-	for _, c := range all {
-		if strings.EqualFold(c.Name, name) || c.Id == name {
-			cc := c
-			return &cc, nil
-		}
+// execContainerCommand 构造在容器内执行命令的进程：重新执行当前程序的隐藏命令 exec-container，
+// 由它加入容器的 namespace 后执行 args，exec 和健康检查共用
+func execContainerCommand(ctx context.Context, info *models.Info, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", "exec-container")
+	cmd.Args = append(cmd.Args, args...)
+
+	// 使用容器的环境变量而不是宿主机的，并传递目标进程 pid
+	env := append([]string{}, info.Env...)
+	if !hasEnv(env, "PATH") {
+		env = append(env, DefaultPathEnv)
 	}
-	return nil, fmt.Errorf("container with name %s not found", name)
+	env = append(env, ExecTargetPidEnv+"="+strconv.Itoa(info.Pid))
+	cmd.Env = env
+	return cmd
 }
@@ -74,7 +74,8 @@ func ExecContainer(args cli.Args) error {
 		argv = append(argv, args.Get(i))
 	}
 
-	// replace current process image
+	// replace current process image，去掉内部使用的环境变量
+	os.Unsetenv(ExecTargetPidEnv)
 	return syscall.Exec(cmdPath, argv, os.Environ())
 }
 
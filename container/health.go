@@ -0,0 +1,165 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// 健康检查参数的默认值，与 docker 一致
+const (
+	DefaultHealthInterval = 30 * time.Second
+	DefaultHealthTimeout  = 30 * time.Second
+	DefaultHealthRetries  = 3
+	// maxHealthOutputLength 每次探测保留的输出长度
+	maxHealthOutputLength = 4096
+)
+
+// NewHealthConfig 根据 --health-* 参数生成健康检查配置，cmd 通过容器内的 /bin/sh -c 执行
+func NewHealthConfig(cmd string, interval, timeout, startPeriod time.Duration, retries int) (*models.HealthConfig, error) {
+	if cmd == "" {
+		return nil, nil
+	}
+	if interval < 0 || timeout < 0 || startPeriod < 0 || retries < 0 {
+		return nil, errors.New("health check options cannot be negative")
+	}
+	config := &models.HealthConfig{
+		Test:        []string{"/bin/sh", "-c", cmd},
+		Interval:    interval,
+		Timeout:     timeout,
+		Retries:     retries,
+		StartPeriod: startPeriod,
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultHealthInterval
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultHealthTimeout
+	}
+	if config.Retries == 0 {
+		config.Retries = DefaultHealthRetries
+	}
+	return config, nil
+}
+
+// startHealthCheck 在后台按间隔探测容器的健康状态，返回的函数用于停止探测并等待探测协程退出。
+// 由持有容器init进程的监控进程在每次启动容器后调用
+func startHealthCheck(info *models.Info) func() {
+	if info.Healthcheck == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runHealthCheck(ctx, info)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// runHealthCheck 循环执行探测并记录结果，容器变为 unhealthy 且重启策略允许时结束容器，由监控进程按重启策略重新拉起
+func runHealthCheck(ctx context.Context, info *models.Info) {
+	config := info.Healthcheck
+	startedAt := time.Now()
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 暂停的容器无法响应探测，跳过
+		if latest, err := models.GetContainerInfo(info.Id); err == nil &&
+			latest.State == models.ContainerStatePaused {
+			continue
+		}
+		entry := probeHealth(ctx, info, config)
+		if ctx.Err() != nil {
+			return
+		}
+		inStartPeriod := time.Since(startedAt) < config.StartPeriod
+		latest, becameUnhealthy, err := recordHealth(info.Id, entry, inStartPeriod)
+		if err != nil {
+			logger.Error("Failed to record health of container %s: %v", info.Id, err)
+			continue
+		}
+		if becameUnhealthy && latest.RestartPolicy.Name != models.RestartPolicyNo && !latest.ManuallyStopped {
+			logger.Warn("container %s is unhealthy, killing it to apply restart policy %s", info.Id, latest.RestartPolicy.Name)
+			if err := syscall.Kill(info.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+				logger.Error("Failed to kill unhealthy container %s: %v", info.Id, err)
+			}
+			return
+		}
+	}
+}
+
+// probeHealth 在容器内执行一次探测命令，超时或无法执行时退出码为 -1
+func probeHealth(ctx context.Context, info *models.Info, config *models.HealthConfig) models.HealthLogEntry {
+	entry := models.HealthLogEntry{Start: time.Now().Format(time.RFC3339Nano)}
+	probeCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	cmd := execContainerCommand(probeCtx, info, config.Test)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	entry.End = time.Now().Format(time.RFC3339Nano)
+
+	var exitErr *exec.ExitError
+	switch {
+	case probeCtx.Err() == context.DeadlineExceeded:
+		entry.ExitCode = -1
+		output.WriteString("health check timed out after " + config.Timeout.String())
+	case errors.As(err, &exitErr):
+		entry.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		entry.ExitCode = -1
+		output.WriteString(err.Error())
+	}
+	entry.Output = output.String()
+	if len(entry.Output) > maxHealthOutputLength {
+		entry.Output = entry.Output[:maxHealthOutputLength]
+	}
+	return entry
+}
+
+// recordHealth 将探测结果写入容器记录，返回容器是否刚刚变为 unhealthy
+func recordHealth(containerID string, entry models.HealthLogEntry, inStartPeriod bool) (*models.Info, bool, error) {
+	var previous string
+	info, err := models.UpdateContainerInfo(containerID, func(info *models.Info) bool {
+		if info.Health == nil {
+			info.Health = &models.Health{Status: models.HealthStarting}
+		}
+		health := info.Health
+		previous = health.Status
+		if entry.ExitCode == 0 {
+			health.Status = models.HealthHealthy
+			health.FailingStreak = 0
+		} else if !inStartPeriod || health.Status != models.HealthStarting {
+			// 启动期内容器还没有变为 healthy 时，失败不计入连续失败次数
+			health.FailingStreak++
+			if health.FailingStreak >= info.Healthcheck.Retries {
+				health.Status = models.HealthUnhealthy
+			}
+		}
+		health.Log = append(health.Log, entry)
+		if len(health.Log) > models.MaxHealthLogEntries {
+			health.Log = health.Log[len(health.Log)-models.MaxHealthLogEntries:]
+		}
+		return true
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return info, previous != models.HealthUnhealthy && info.Health.Status == models.HealthUnhealthy, nil
+}
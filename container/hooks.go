@@ -0,0 +1,132 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/oci"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// DefaultGlobalHooksPath 全局钩子配置，对所有容器生效，先于容器自己的钩子执行
+	DefaultGlobalHooksPath = "/etc/tinydocker/hooks.json"
+	// DefaultHookTimeout 钩子没有指定超时时间时的默认值，避免钩子卡住容器的生命周期
+	DefaultHookTimeout = 30 * time.Second
+)
+
+// 钩子类型
+const (
+	HookPrestart  = "prestart"
+	HookPoststart = "poststart"
+	HookPoststop  = "poststop"
+)
+
+// ReadHooksFile 读取 --hooks 指定的钩子配置文件，格式与 OCI config.json 中的 hooks 一致
+func ReadHooksFile(path string) (*oci.Hooks, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read hooks file %s error: %v", path, err)
+	}
+	var hooks oci.Hooks
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("parse hooks file %s error: %v", path, err)
+	}
+	if err := hooks.Validate(); err != nil {
+		return nil, err
+	}
+	return &hooks, nil
+}
+
+// containerHooks 返回容器某个阶段需要执行的钩子：全局钩子在前，容器自己的钩子在后
+func containerHooks(info *models.Info, kind string) ([]oci.Hook, error) {
+	var hooks []oci.Hook
+	var global *oci.Hooks
+	if _, err := os.Stat(DefaultGlobalHooksPath); err == nil {
+		if global, err = ReadHooksFile(DefaultGlobalHooksPath); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range []*oci.Hooks{global, info.Hooks} {
+		if h == nil {
+			continue
+		}
+		switch kind {
+		case HookPrestart:
+			hooks = append(hooks, h.Prestart...)
+		case HookPoststart:
+			hooks = append(hooks, h.Poststart...)
+		case HookPoststop:
+			hooks = append(hooks, h.Poststop...)
+		}
+	}
+	return hooks, nil
+}
+
+// runHooks 依次执行容器某个阶段的钩子，容器状态（OCI state JSON）通过标准输入传给钩子。
+// 任意一个钩子失败时停止执行并返回错误，由调用方决定失败的后果：
+// prestart 失败时容器启动失败，poststart 和 poststop 失败只记录日志
+func runHooks(info *models.Info, kind string) error {
+	hooks, err := containerHooks(info, kind)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	// 钩子执行时容器记录中的状态可能还没有更新，按钩子的阶段设置状态
+	state := ociState(info)
+	switch kind {
+	case HookPrestart:
+		state.Status = oci.StateCreated
+	case HookPoststart:
+		state.Status = oci.StateRunning
+	case HookPoststop:
+		state.Status = oci.StateStopped
+		state.Pid = 0
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if err := runHook(hook, data); err != nil {
+			return fmt.Errorf("%s hook %s failed: %v", kind, hook.Path, err)
+		}
+	}
+	return nil
+}
+
+// runHook 执行单个钩子，超时后结束钩子进程
+func runHook(hook oci.Hook, state []byte) error {
+	timeout := DefaultHookTimeout
+	if hook.Timeout != nil {
+		timeout = time.Duration(*hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Path)
+	if len(hook.Args) > 0 {
+		cmd.Args = hook.Args
+	}
+	// 钩子只获得配置中指定的环境变量，与 OCI 的约定一致
+	cmd.Env = hook.Env
+	cmd.Stdin = bytes.NewReader(state)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if output.Len() > 0 {
+		logger.Debug("hook %s output: %s", hook.Path, output.String())
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(output.Bytes()))
+	}
+	return nil
+}
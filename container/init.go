@@ -1,16 +1,21 @@
 package container
 
 import (
-	"errors"
+	"encoding/json"
+	"fmt"
 	"github.com/phper95/tinydocker/filesys"
 	"github.com/phper95/tinydocker/pkg/logger"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
 )
 
+// oPath 即 O_PATH，只获取文件引用而不打开文件，syscall 包中没有定义
+const oPath = 0x200000
+
 func init() {
 	logger.SetLevel(logger.DEBUG)
 	logger.SetOutput(os.Stdout)
@@ -18,7 +23,7 @@ func init() {
 }
 
 func InitContainerProcess() error {
-	// 从管道中读取用户传递过来的命令参数
+	// 从管道中读取父进程传递过来的启动规格
 	// 0-stdin
 	// 1-stdout
 	// 2-stderr
@@ -29,35 +34,284 @@ func InitContainerProcess() error {
 	msg, err := io.ReadAll(pipe)
 	if err != nil {
 		logger.Error("init read pipe error %v", err)
-		return nil
+		return err
 	}
 
-	msgStr := string(msg)
-	cmdArgs := strings.Split(msgStr, " ")
-	if len(cmdArgs) == 0 {
-		logger.Error("InitContainerProcess user cmd is empty", cmdArgs)
-		return errors.New("InitContainerProcess user cmd is empty")
+	var spec InitSpec
+	if err := json.Unmarshal(msg, &spec); err != nil {
+		logger.Error("InitContainerProcess unmarshal init spec error: %v", err)
+		return fmt.Errorf("invalid init spec: %v", err)
 	}
-	logger.Debug("InitContainerProcess user cmd: ", cmdArgs)
-	err = filesys.Mount()
-	if err != nil {
-		logger.Error("Failed to mount proc: ", err)
+	if err := spec.Validate(); err != nil {
+		logger.Error("InitContainerProcess invalid init spec: %v", err)
 		return err
 	}
+	logger.Debug("InitContainerProcess init spec: %+v", spec)
 
-	// 在系统的PATH中寻找命令的绝对路径(因为用户可能只输入了命令名而没有输入绝对路径)
-	path, err := exec.LookPath(cmdArgs[0])
+	// exec.fifo 位于宿主机的容器目录中，pivot_root 之后无法访问，先以 O_PATH 打开保留引用
+	fifoFd := -1
+	if spec.ExecFifo != "" {
+		if fifoFd, err = syscall.Open(spec.ExecFifo, oPath|syscall.O_CLOEXEC, 0); err != nil {
+			logger.Error("Failed to open exec fifo %s: %v", spec.ExecFifo, err)
+			return err
+		}
+	}
+
+	if err := setupRootfs(&spec); err != nil {
+		return err
+	}
+	if spec.Hostname != "" {
+		if err := syscall.Sethostname([]byte(spec.Hostname)); err != nil {
+			logger.Error("Failed to set hostname: ", err)
+			return err
+		}
+	}
+	if spec.Domain != "" {
+		if err := syscall.Setdomainname([]byte(spec.Domain)); err != nil {
+			logger.Error("Failed to set domainname: ", err)
+			return err
+		}
+	}
+	if err := setRlimits(spec.Rlimits); err != nil {
+		return err
+	}
+	if spec.Cwd != "" {
+		// 与 docker 一致，工作目录不存在时自动创建
+		if err := os.MkdirAll(spec.Cwd, 0755); err != nil {
+			logger.Error("Failed to create workdir %s: %v", spec.Cwd, err)
+			return err
+		}
+		if err := os.Chdir(spec.Cwd); err != nil {
+			logger.Error("Failed to chdir to %s: %v", spec.Cwd, err)
+			return err
+		}
+	}
+
+	// 在用户进程的 PATH 中寻找命令的绝对路径(因为用户可能只输入了命令名而没有输入绝对路径)
+	setLookupPath(spec.Env)
+	path, err := exec.LookPath(spec.Args[0])
 	if err != nil {
 		logger.Error("exec look path error %v", err)
 		return err
 	}
 	logger.Debug("InitContainerProcess user cmd abs path: ", path)
 
+	if fifoFd >= 0 {
+		if err := waitExecFifo(fifoFd); err != nil {
+			return err
+		}
+	}
+
+	user, err := lookupUser(spec.User)
+	if err != nil {
+		logger.Error("Failed to lookup user %s: %v", spec.User, err)
+		return err
+	}
+	if err := setUser(user, spec.AdditionalGids); err != nil {
+		return err
+	}
+	env := spec.Env
+	if !hasEnv(env, "HOME") {
+		env = append(env, "HOME="+user.Home)
+	}
+
+	if spec.Init {
+		return runReaper(path, spec.Args, env)
+	}
+
 	// init进程读取了父进程传递过来的参数，在子进程内执行，完成了将用户指定命令传递给子进程的操作
-	err = syscall.Exec(path, cmdArgs, os.Environ())
+	err = syscall.Exec(path, spec.Args, env)
 	if err != nil {
 		logger.Error("Failed to exec command: ", err)
 	}
+	return err
+}
+
+// setupRootfs 将当前工作目录切换为容器根目录，然后按启动规格挂载 /proc、/dev 等文件系统
+func setupRootfs(spec *InitSpec) error {
+	pwd, err := os.Getwd()
+	if err != nil {
+		logger.Error("Get current location error %v", err)
+		return err
+	}
+	// 绑定挂载的源路径在宿主机上，需要在 pivot_root 之前挂载到新根目录下，
+	// 挂载前先将挂载点设为私有，避免传播到宿主机
+	if err := syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, ""); err != nil {
+		logger.Error("Failed to make mounts private: ", err)
+		return err
+	}
+	for _, m := range spec.Mounts {
+		if m.Flags&syscall.MS_BIND != 0 {
+			if err := mountBind(m, filepath.Join(pwd, m.Target)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := filesys.MountPivotRoot(pwd); err != nil {
+		logger.Error("Failed to mount pivot root error: ", err)
+		return err
+	}
+	for _, m := range spec.Mounts {
+		if m.Flags&syscall.MS_BIND != 0 {
+			continue
+		}
+		if err := os.MkdirAll(m.Target, 0755); err != nil {
+			logger.Error("Failed to create mount target %s: %v", m.Target, err)
+			return err
+		}
+		if err := syscall.Mount(m.Source, m.Target, m.Type, m.Flags, m.Data); err != nil {
+			logger.Error("Failed to mount %s to %s: %v", m.Source, m.Target, err)
+			return err
+		}
+	}
+	if spec.DefaultDevices {
+		if err := createDefaultDevices(); err != nil {
+			return err
+		}
+	}
+	if spec.ReadonlyRootfs {
+		if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY, ""); err != nil {
+			logger.Error("Failed to remount rootfs readonly: ", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// mountBind 将宿主机路径绑定挂载到 target，只读等标志需要重新挂载一次才能生效
+func mountBind(m MountSpec, target string) error {
+	fi, err := os.Stat(m.Source)
+	if err != nil {
+		logger.Error("Failed to stat bind mount source %s: %v", m.Source, err)
+		return err
+	}
+	// 绑定挂载的目标需要与源的类型一致
+	if fi.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(target, os.O_CREATE, 0644); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		logger.Error("Failed to create bind mount target %s: %v", target, err)
+		return err
+	}
+	if err := syscall.Mount(m.Source, target, "bind", m.Flags, m.Data); err != nil {
+		logger.Error("Failed to bind mount %s to %s: %v", m.Source, target, err)
+		return err
+	}
+	if m.Flags&^(syscall.MS_BIND|syscall.MS_REC) != 0 {
+		if err := syscall.Mount("", target, "", m.Flags|syscall.MS_REMOUNT, ""); err != nil {
+			logger.Error("Failed to remount %s: %v", target, err)
+			return err
+		}
+	}
+	return nil
+}
 
+// defaultDevices OCI runtime-spec 规定容器中必须存在的设备
+var defaultDevices = []struct {
+	path         string
+	major, minor uint32
+}{
+	{"/dev/null", 1, 3},
+	{"/dev/zero", 1, 5},
+	{"/dev/full", 1, 7},
+	{"/dev/random", 1, 8},
+	{"/dev/urandom", 1, 9},
+	{"/dev/tty", 5, 0},
+}
+
+// createDefaultDevices 在 /dev 中创建默认的字符设备和标准输入输出的软链接，已存在的跳过
+func createDefaultDevices() error {
+	for _, d := range defaultDevices {
+		dev := int(d.major<<8 | d.minor)
+		if err := syscall.Mknod(d.path, syscall.S_IFCHR|0666, dev); err != nil && err != syscall.EEXIST {
+			logger.Error("Failed to create device %s: %v", d.path, err)
+			return err
+		}
+	}
+	links := [][2]string{
+		{"/proc/self/fd", "/dev/fd"},
+		{"/proc/self/fd/0", "/dev/stdin"},
+		{"/proc/self/fd/1", "/dev/stdout"},
+		{"/proc/self/fd/2", "/dev/stderr"},
+	}
+	for _, l := range links {
+		if err := os.Symlink(l[0], l[1]); err != nil && !os.IsExist(err) {
+			logger.Error("Failed to create symlink %s: %v", l[1], err)
+			return err
+		}
+	}
 	return nil
 }
+
+// waitExecFifo 以写方式打开 exec.fifo，阻塞到 runtime start 以读方式打开，
+// fifoFd 是 pivot_root 之前以 O_PATH 打开的文件描述符
+func waitExecFifo(fifoFd int) error {
+	fifo, err := os.OpenFile(fmt.Sprintf("/proc/self/fd/%d", fifoFd), os.O_WRONLY, 0)
+	if err != nil {
+		logger.Error("Failed to open exec fifo: ", err)
+		return err
+	}
+	defer fifo.Close()
+	syscall.Close(fifoFd)
+	if _, err := fifo.Write([]byte("0")); err != nil {
+		logger.Error("Failed to write exec fifo: ", err)
+		return err
+	}
+	return nil
+}
+
+// setRlimits 设置用户进程的资源限制，exec 之后依然生效
+func setRlimits(rlimits []Rlimit) error {
+	for _, rl := range rlimits {
+		limit := &syscall.Rlimit{Cur: rl.Soft, Max: rl.Hard}
+		if err := syscall.Setrlimit(rlimitTypes[rl.Type], limit); err != nil {
+			logger.Error("Failed to set rlimit %s: %v", rl.Type, err)
+			return fmt.Errorf("set rlimit %s error: %v", rl.Type, err)
+		}
+	}
+	return nil
+}
+
+// setLookupPath 使用用户进程环境变量中的 PATH 查找命令
+func setLookupPath(env []string) {
+	for _, e := range env {
+		if strings.HasPrefix(e, "PATH=") {
+			os.Setenv("PATH", strings.TrimPrefix(e, "PATH="))
+		}
+	}
+}
+
+// setUser 设置附加组并切换到解析后的用户运行，需要在 exec 之前最后执行
+func setUser(user *execUser, additionalGids []uint32) error {
+	groups := append([]int{}, user.Sgids...)
+	for _, gid := range additionalGids {
+		groups = append(groups, int(gid))
+	}
+	// 先设置附加组，未指定时清空从宿主机继承的附加组
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("setgroups %v error: %v", groups, err)
+	}
+	// 先切换组再切换用户，切换用户后不再有权限修改组
+	if err := syscall.Setgid(user.Gid); err != nil {
+		return fmt.Errorf("setgid %d error: %v", user.Gid, err)
+	}
+	if err := syscall.Setuid(user.Uid); err != nil {
+		return fmt.Errorf("setuid %d error: %v", user.Uid, err)
+	}
+	return nil
+}
+
+// hasEnv 判断环境变量列表中是否设置了 key
+func hasEnv(env []string, key string) bool {
+	for _, e := range env {
+		if strings.HasPrefix(e, key+"=") {
+			return true
+		}
+	}
+	return false
+}
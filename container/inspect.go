@@ -0,0 +1,158 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/phper95/tinydocker/cgroups"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/pkg/logger"
+)
+
+// ContainerInspect inspect 命令输出的容器详情，在容器记录的基础上补充运行时信息
+type ContainerInspect struct {
+	*models.Info
+	Mounts     []InspectMount `json:"mounts"`      // 容器的所有挂载，包括根文件系统
+	CgroupPath string         `json:"cgroup_path"` // 容器 cgroup 的完整路径
+	LogPath    string         `json:"log_path"`    // 后台容器的日志文件
+}
+
+// InspectMount 容器的一个挂载点
+type InspectMount struct {
+	Type        string   `json:"type"`
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// VolumeInspect inspect 命令输出的数据卷详情。数据卷是 -v 指定的宿主机目录，没有单独的数据卷记录，
+// 由使用该目录的容器汇总得到
+type VolumeInspect struct {
+	Name       string        `json:"name"`       // 宿主机目录，与 -v 中指定的一致
+	Type       string        `json:"type"`       // 挂载类型，目前只有 bind
+	Exists     bool          `json:"exists"`     // 宿主机目录当前是否存在
+	Containers []VolumeMount `json:"containers"` // 挂载了该目录的容器
+}
+
+// VolumeMount 挂载了数据卷的一个容器
+type VolumeMount struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	Destination string `json:"destination"` // 容器内的挂载点
+}
+
+// mountFlagNames 挂载标志对应的选项名，按输出顺序排列
+var mountFlagNames = []struct {
+	flag uintptr
+	name string
+}{
+	{syscall.MS_RDONLY, "ro"},
+	{syscall.MS_NOSUID, "nosuid"},
+	{syscall.MS_NODEV, "nodev"},
+	{syscall.MS_NOEXEC, "noexec"},
+	{syscall.MS_SYNCHRONOUS, "sync"},
+	{syscall.MS_NOATIME, "noatime"},
+	{syscall.MS_RELATIME, "relatime"},
+	{syscall.MS_STRICTATIME, "strictatime"},
+	{syscall.MS_REC, "rec"},
+	{syscall.MS_BIND, "bind"},
+}
+
+// Inspect 返回容器的完整配置和运行时信息
+func Inspect(containerName string) (*ContainerInspect, error) {
+	info, err := ResolveContainer(containerName)
+	if err != nil {
+		return nil, err
+	}
+	result := &ContainerInspect{
+		Info:       info,
+		CgroupPath: filepath.Join(cgroups.CgroupRoot, cgroups.ContainerCgroupName(info.Id)),
+		Mounts:     containerMounts(info),
+	}
+	if info.Detach {
+		result.LogPath = filepath.Join(models.DefaultContainerInfoPath, info.Id, DefaultContainerLogFileName)
+	}
+	return result, nil
+}
+
+// InspectVolume 按宿主机目录查找 -v 挂载的数据卷，没有容器挂载该目录时返回错误
+func InspectVolume(name string) (*VolumeInspect, error) {
+	name = filepath.Clean(name)
+	result := &VolumeInspect{Name: name, Type: "bind"}
+	for _, info := range models.ReadContainersInfo() {
+		hostDir, containerDir, ok := strings.Cut(info.Volume, ":")
+		if !ok || filepath.Clean(hostDir) != name {
+			continue
+		}
+		result.Containers = append(result.Containers, VolumeMount{
+			Id:          info.Id,
+			Name:        info.Name,
+			State:       info.State,
+			Destination: containerDir,
+		})
+	}
+	if len(result.Containers) == 0 {
+		return nil, fmt.Errorf("no such volume: %s", name)
+	}
+	if _, err := os.Stat(name); err == nil {
+		result.Exists = true
+	}
+	return result, nil
+}
+
+// containerMounts 按挂载顺序列出容器的根文件系统、数据卷和 init 进程挂载的文件系统
+func containerMounts(info *models.Info) []InspectMount {
+	var mounts []InspectMount
+	if info.Bundle == "" {
+		containerDir := filepath.Join(models.DefaultContainerInfoPath, info.Id)
+		mounts = append(mounts, InspectMount{
+			Type:        "overlay",
+			Source:      "overlay",
+			Destination: "/",
+			Options: []string{
+				"lowerdir=" + filepath.Join(models.DefaultImagePath, info.Image),
+				"upperdir=" + filepath.Join(containerDir, "upper"),
+				"workdir=" + filepath.Join(containerDir, "work"),
+			},
+		})
+		if hostDir, containerDir, ok := strings.Cut(info.Volume, ":"); ok {
+			mounts = append(mounts, InspectMount{Type: "bind", Source: hostDir, Destination: containerDir, Options: []string{"bind"}})
+		}
+	} else {
+		mounts = append(mounts, InspectMount{Type: "bind", Source: info.Rootfs, Destination: "/", Options: []string{"bind"}})
+	}
+
+	spec, err := NewInitSpec(info)
+	if err != nil {
+		// bundle 被删除等情况下只返回根文件系统
+		logger.Warn("get mounts of container %s error: %v", info.Id, err)
+		return mounts
+	}
+	for _, m := range spec.Mounts {
+		mounts = append(mounts, InspectMount{
+			Type:        m.Type,
+			Source:      m.Source,
+			Destination: m.Target,
+			Options:     mountOptions(m.Flags, m.Data),
+		})
+	}
+	return mounts
+}
+
+// mountOptions 将挂载标志和挂载数据转换为可读的选项列表
+func mountOptions(flags uintptr, data string) []string {
+	var options []string
+	for _, f := range mountFlagNames {
+		if flags&f.flag != 0 {
+			options = append(options, f.name)
+		}
+	}
+	if data != "" {
+		options = append(options, strings.Split(data, ",")...)
+	}
+	return options
+}
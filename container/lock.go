@@ -0,0 +1,49 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+// lockContainer 解析容器并获取容器的生命周期锁，返回加锁后重新读取的配置：
+// 等待锁期间容器可能已经被其他命令修改或删除
+func lockContainer(containerName string) (*models.Info, func(), error) {
+	info, err := ResolveContainer(containerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lockResolvedContainer(containerName, info)
+}
+
+// lockContainerByID 与 lockContainer 相同，但只按完整ID查找容器，供 OCI runtime 命令使用
+func lockContainerByID(containerID string) (*models.Info, func(), error) {
+	info, err := GetContainerByID(containerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lockResolvedContainer(containerID, info)
+}
+
+// lockResolvedContainer 获取已查找到的容器的生命周期锁，并在加锁后重新读取配置
+func lockResolvedContainer(containerName string, info *models.Info) (*models.Info, func(), error) {
+	unlock, err := models.LockContainer(info.Id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerName)
+		}
+		return nil, nil, err
+	}
+	latest, err := models.GetContainerInfo(info.Id)
+	if err != nil {
+		unlock()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerName)
+		}
+		return nil, nil, err
+	}
+	models.ReconcileContainerState(latest)
+	return latest, unlock, nil
+}
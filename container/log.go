@@ -15,7 +15,12 @@ const (
 	DefaultContainerLogFileName = "container.log"
 )
 
-func PrintContainerLogs(containerID string, follow bool) error {
+func PrintContainerLogs(containerName string, follow bool) error {
+	info, err := ResolveContainer(containerName)
+	if err != nil {
+		return err
+	}
+	containerID := info.Id
 	logDir := filepath.Join(models.DefaultContainerInfoPath, containerID)
 	logFilePath := filepath.Join(logDir, DefaultContainerLogFileName)
 	// Check if log file exists
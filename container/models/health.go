@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// 容器健康状态
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// MaxHealthLogEntries Health.Log 中保留的最近探测记录数
+const MaxHealthLogEntries = 5
+
+// HealthConfig 健康检查配置
+type HealthConfig struct {
+	Test        []string      `json:"test"`         // 在容器内执行的探测命令
+	Interval    time.Duration `json:"interval"`     // 两次探测的间隔
+	Timeout     time.Duration `json:"timeout"`      // 单次探测的超时时间
+	Retries     int           `json:"retries"`      // 连续失败多少次后判定为 unhealthy
+	StartPeriod time.Duration `json:"start_period"` // 启动期内的失败不计入连续失败次数
+}
+
+// Health 容器当前的健康状态
+type Health struct {
+	Status        string           `json:"status"`         // starting、healthy、unhealthy
+	FailingStreak int              `json:"failing_streak"` // 连续失败的次数
+	Log           []HealthLogEntry `json:"log"`            // 最近的探测记录
+}
+
+// HealthLogEntry 一次探测的结果
+type HealthLogEntry struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
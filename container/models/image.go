@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// DefaultImageConfigSuffix 镜像配置文件后缀，保存在 DefaultImagePath/<image>.json
+	DefaultImageConfigSuffix = ".json"
+	// DefaultImageTarDir 镜像 tar 包所在目录，镜像名为 <name> 的 tar 包是 DefaultImageTarDir/<name>.tar
+	DefaultImageTarDir = "/var/local"
+	// DefaultImageTarSuffix 镜像 tar 包后缀
+	DefaultImageTarSuffix = ".tar"
+)
+
+// ImageConfig 镜像的默认运行配置，与镜像解压目录放在一起
+type ImageConfig struct {
+	Env    []string          `json:"env"`              // 镜像默认的环境变量 KEY=VALUE
+	Labels map[string]string `json:"labels,omitempty"` // 用户自定义的元数据
+}
+
+// GetImageTarPath 返回镜像 tar 包路径
+func GetImageTarPath(imageName string) string {
+	return filepath.Join(DefaultImageTarDir, imageName+DefaultImageTarSuffix)
+}
+
+// GetImageConfigPath 返回镜像配置文件路径
+func GetImageConfigPath(imageName string) string {
+	return filepath.Join(DefaultImagePath, imageName+DefaultImageConfigSuffix)
+}
+
+// ReadImageConfig 读取镜像的默认配置，镜像没有配置文件时返回空配置
+func ReadImageConfig(imageName string) (*ImageConfig, error) {
+	config := &ImageConfig{}
+	data, err := os.ReadFile(GetImageConfigPath(imageName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parse image %s config error: %v", imageName, err)
+	}
+	return config, nil
+}
+
+// WriteImageConfig 保存镜像的默认配置
+func WriteImageConfig(imageName string, config *ImageConfig) error {
+	if err := os.MkdirAll(DefaultImagePath, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(GetImageConfigPath(imageName), data, 0644)
+}
@@ -2,12 +2,12 @@ package models
 
 import (
 	"crypto/rand"
-	"encoding/json"
 	"fmt"
+	"github.com/phper95/tinydocker/oci"
+	"github.com/phper95/tinydocker/pkg/filters"
 	"github.com/phper95/tinydocker/pkg/logger"
 	"io"
 	"os"
-	"path/filepath"
 	"text/tabwriter"
 	"time"
 )
@@ -15,64 +15,97 @@ import (
 const (
 	DefaultContainerInfoPath     = "/var/lib/tinydocker/containers"
 	DefaultImagePath             = "/var/lib/tinydocker/image"
-	DefaultContainerInfoFileName = "config.json"
+	DefaultContainerInfoFileName = "config.json" // 旧版本保存容器记录的文件，现在只用于导入
+	ContainerStateCreated        = "created"
 	ContainerStateRunning        = "running"
 	ContainerStateStopped        = "stopped"
+	ContainerStateRestarting     = "restarting"
+	ContainerStatePaused         = "paused"
 )
 
-type Info struct {
-	Id          string   `json:"id"`          // 容器Id
-	Name        string   `json:"name"`        // 容器名
-	Pid         int      `json:"pid"`         // 容器的init进程在宿主机上的 PID
-	Command     string   `json:"command"`     // 容器内init运行命令
-	State       string   `json:"state"`       // 容器的状态
-	StartedAt   string   `json:"started_at"`  // 启动时间
-	FinishedAt  string   `json:"finished_at"` // 结束时间
-	Image       string   `json:"image"`       // 容器使用的镜像名称
-	Network     string   `json:"network"`
-	IpAddress   string   `json:"ipAddress"`
-	PortMapping []string `json:"port_mapping"` // 端口映射
+// 容器重启策略
+const (
+	RestartPolicyNo            = "no"
+	RestartPolicyOnFailure     = "on-failure"
+	RestartPolicyAlways        = "always"
+	RestartPolicyUnlessStopped = "unless-stopped"
+)
+
+// RestartPolicy 容器退出后的重启策略
+type RestartPolicy struct {
+	Name              string `json:"name"`                // 策略名称
+	MaximumRetryCount int    `json:"maximum_retry_count"` // on-failure 的最大重启次数，0表示不限制
 }
 
-func WriteContainerInfo(info *Info) error {
-	// 序列化Info结构体到json字符串
-	jsonStr, err := json.Marshal(info)
-	if err != nil {
-		return err
-	}
-	// 写入json字符串到文件
-	dirPath := filepath.Join(DefaultContainerInfoPath, info.Id)
-	if err := os.MkdirAll(dirPath, 0622); err != nil {
-		logger.Error("mkdirall error: ", err)
-		return err
-	}
-	filePath := filepath.Join(dirPath, DefaultContainerInfoFileName)
-	err = os.WriteFile(filePath, jsonStr, 0622)
-	if err != nil {
-		logger.Error("writefile error: ", err)
-		return err
-	}
-	return nil
+// NetworkEndpoint 容器连接网络时在宿主机上创建的资源，停止或删除容器时据此撤销，
+// 保存在容器记录中，CLI 异常退出后依然可以清理
+type NetworkEndpoint struct {
+	Network   string   `json:"network"`    // 网络名称
+	IPAddress string   `json:"ip_address"` // 容器IP
+	HostVeth  string   `json:"host_veth"`  // 宿主机一端的 veth 设备名
+	NatRules  []string `json:"nat_rules"`  // nat 表 PREROUTING 链中添加的端口映射规则
 }
 
-func UpdateContainerState(containerID string, state string) error {
-	// 构建容器配置文件路径
-	filePath := filepath.Join(DefaultContainerInfoPath, containerID, DefaultContainerInfoFileName)
+type Info struct {
+	Id           string           `json:"id"`             // 容器Id
+	Name         string           `json:"name"`           // 容器名
+	Pid          int              `json:"pid"`            // 容器的init进程在宿主机上的 PID
+	PidStartTime uint64           `json:"pid_start_time"` // init进程的启动时间，用于识别 PID 复用
+	Command      string           `json:"command"`        // 容器内init运行命令
+	State        string           `json:"state"`          // 容器的状态
+	CreatedAt    string           `json:"created_at"`     // 创建时间
+	StartedAt    string           `json:"started_at"`     // 启动时间
+	FinishedAt   string           `json:"finished_at"`    // 结束时间
+	ExitCode     int              `json:"exit_code"`      // 容器init进程的退出码
+	ExitSignal   string           `json:"exit_signal"`    // 终止容器init进程的信号
+	OOMKilled    bool             `json:"oom_killed"`     // 是否因超出内存限制被杀死
+	Error        string           `json:"error"`          // 启动或运行容器时的错误信息
+	Image        string           `json:"image"`          // 容器使用的镜像名称
+	Network      string           `json:"network"`
+	IpAddress    string           `json:"ipAddress"`
+	Endpoint     *NetworkEndpoint `json:"endpoint"`     // 容器连接网络时在宿主机上创建的资源
+	PortMapping  []string         `json:"port_mapping"` // 端口映射
+	Args         []string         `json:"args"`         // 容器内init运行命令的完整参数列表
+	Env          []string         `json:"env"`          // 容器进程最终的环境变量
+	Volume       string           `json:"volume"`       // 数据卷挂载 hostDir:containerDir
+	MemoryLimit  string           `json:"memory_limit"` // 内存限制
+	CpuLimit     string           `json:"cpu_limit"`    // CPU限制
+	PidsLimit    int64            `json:"pids_limit"`   // 进程数限制，0 表示不限制
+	TTY          bool             `json:"tty"`          // 是否为交互模式
+	Detach       bool             `json:"detach"`       // 是否后台运行
+	StopSignal   string           `json:"stop_signal"`  // stop 时发送给容器的信号，默认 SIGTERM
+	Ulimits      []string         `json:"ulimits"`      // 资源限制 name=soft[:hard]
+	WorkingDir   string           `json:"working_dir"`  // 用户进程的工作目录，为空时使用 /
+	User         string           `json:"user"`         // 运行用户 <name|uid>[:<group|gid>]，为空时使用 root
+	Hostname     string           `json:"hostname"`     // 容器主机名，为空时使用容器ID的前12位
+	Domainname   string           `json:"domainname"`   // 容器 NIS 域名
+	AutoRemove   bool             `json:"auto_remove"`  // 容器退出后自动删除（--rm）
+	Init         bool             `json:"init"`         // 是否在容器内运行 init 进程回收孤儿进程并转发信号
+	Hooks        *oci.Hooks       `json:"hooks"`        // 容器的生命周期钩子
+	Bundle       string           `json:"bundle"`       // OCI bundle 目录，为空表示由镜像创建的容器
+	Rootfs       string           `json:"rootfs"`       // OCI bundle 容器的根文件系统，不使用 overlay
 
-	// 读取现有配置
-	info, err := ReadContainerInfo(filePath)
-	if err != nil {
-		return err
-	}
+	Labels      map[string]string `json:"labels"`      // 用户自定义的元数据
+	Healthcheck *HealthConfig     `json:"healthcheck"` // 健康检查配置，为空表示不检查
+	Health      *Health           `json:"health"`      // 最近一次运行的健康状态
 
-	// 更新状态
-	info.State = state
-	if state == ContainerStateStopped {
-		info.FinishedAt = time.Now().Format(time.DateTime)
-	}
+	RestartPolicy   RestartPolicy `json:"restart_policy"`   // 重启策略
+	RestartCount    int           `json:"restart_count"`    // 自动重启的次数
+	ManuallyStopped bool          `json:"manually_stopped"` // 是否由用户执行 stop 停止
+
+	MonitorPid       int    `json:"monitor_pid"`        // 托管容器的监控进程（shim 或前台 CLI）的 PID
+	MonitorStartTime uint64 `json:"monitor_start_time"` // 监控进程的启动时间，用于识别 PID 复用
+}
 
-	// 写回文件
-	return WriteContainerInfo(info)
+func UpdateContainerState(containerID string, state string) error {
+	_, err := UpdateContainerInfo(containerID, func(info *Info) bool {
+		info.State = state
+		if state == ContainerStateStopped {
+			info.FinishedAt = time.Now().Format(time.DateTime)
+		}
+		return true
+	})
+	return err
 }
 
 func GenerateRandomContainerID() string {
@@ -83,62 +116,102 @@ func GenerateRandomContainerID() string {
 	return fmt.Sprintf("%x", bytes)
 }
 
-func PrintContainersInfo() error {
-	containersInfo := ReadContainersInfo()
-	if len(containersInfo) == 0 {
-		return nil
-	}
-	// 格式化输出表格
-	tableWri := tabwriter.NewWriter(os.Stdout, 6, 2, 1, '\t', 0)
-	fmt.Fprintln(tableWri, "ID\tNAME\tPID\tCOMMAND\tSTATE\tSTARTED_AT\tFINISHED_AT")
-	for _, info := range containersInfo {
-		fmt.Fprintf(tableWri, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
-			info.Id, info.Name, info.Pid, info.Command, info.State, info.StartedAt, info.FinishedAt)
+// ShortIDLength ps 等命令默认显示的容器ID长度
+const ShortIDLength = 12
+
+// maxCommandWidth ps 默认显示的命令最大长度
+const maxCommandWidth = 20
+
+// ContainerFilterKeys ps 和容器列表接口支持的过滤条件
+var ContainerFilterKeys = []string{"label", "status", "name", "network", "ancestor"}
+
+// containerStatusAliases status 过滤条件中与 docker 兼容的状态名，容器记录中已停止的状态保存为 stopped
+var containerStatusAliases = map[string]string{"exited": ContainerStateStopped}
+
+// ParseContainerFilters 解析 ps 和容器列表接口的过滤条件，status 只能是容器的状态，exited 视为 stopped
+func ParseContainerFilters(args []string) (filters.Args, error) {
+	parsed, err := filters.Parse(args, ContainerFilterKeys...)
+	if err != nil {
+		return nil, err
 	}
-	if err := tableWri.Flush(); err != nil {
-		logger.Error("flush error: ", err)
-		return err
+	for i, status := range parsed["status"] {
+		if state, ok := containerStatusAliases[status]; ok {
+			parsed["status"][i] = state
+			continue
+		}
+		switch status {
+		case ContainerStateCreated, ContainerStateRunning, ContainerStateStopped, ContainerStateRestarting, ContainerStatePaused:
+		default:
+			return nil, fmt.Errorf("invalid filter status %q, supported: created, running, paused, restarting, stopped (exited)", status)
+		}
 	}
-	return nil
+	return parsed, nil
 }
 
-func ReadContainersInfo() []Info {
-	// 读取容器信息目录
-	dirs, err := os.ReadDir(DefaultContainerInfoPath)
-	if err != nil {
-		logger.Error("readdir error: ", err)
-		return nil
-	}
-	// 遍历目录，读取每个容器的配置信息
-	var infos []Info
-	for _, d := range dirs {
-		logger.Debug("dir name: ", d.Name())
-		if !d.IsDir() {
+// FilterContainers 返回满足过滤条件的容器，all 为 false 时只返回运行中（包括暂停和重启中）的容器，
+// 指定了 status 过滤条件时以过滤条件为准
+func FilterContainers(infos []Info, all bool, args filters.Args) []Info {
+	var result []Info
+	for _, info := range infos {
+		if !all && !args.Has("status") && !isContainerUp(info.State) {
 			continue
 		}
-		filePath := filepath.Join(DefaultContainerInfoPath, d.Name(), DefaultContainerInfoFileName)
-		info, err := ReadContainerInfo(filePath)
-		if err != nil {
-			logger.Error("readcontainerinfo error: ", err)
+		if !args.Match("status", info.State) ||
+			!args.MatchContains("name", info.Name) ||
+			!args.Match("network", info.Network) ||
+			!args.Match("ancestor", info.Image) ||
+			!args.MatchLabels(info.Labels) {
 			continue
 		}
-		infos = append(infos, *info)
+		result = append(result, info)
 	}
-	return infos
+	return result
 }
 
-func ReadContainerInfo(filePath string) (*Info, error) {
-	// 读取json字符串
-	jsonStr, err := os.ReadFile(filePath)
-	if err != nil {
-		logger.Error("readfile error: ", err)
-		return nil, err
+// isContainerUp 容器的 init 进程是否在运行
+func isContainerUp(state string) bool {
+	switch state {
+	case ContainerStateRunning, ContainerStatePaused, ContainerStateRestarting:
+		return true
 	}
-	// 反序列化json字符串到Info结构体
-	var info Info
-	if err := json.Unmarshal(jsonStr, &info); err != nil {
-		logger.Error("unmarshal error: ", err)
-		return nil, err
+	return false
+}
+
+// TruncateID 返回容器ID的短格式
+func TruncateID(id string) string {
+	if len(id) > ShortIDLength {
+		return id[:ShortIDLength]
+	}
+	return id
+}
+
+// PrintContainersInfo 以表格形式输出容器列表，noTrunc 为 false 时截断ID和命令
+func PrintContainersInfo(containersInfo []Info, noTrunc bool) error {
+	// 格式化输出表格
+	tableWri := tabwriter.NewWriter(os.Stdout, 6, 2, 1, '\t', 0)
+	fmt.Fprintln(tableWri, "ID\tNAME\tPID\tCOMMAND\tSTATE\tRESTARTS\tEXIT_CODE\tSTARTED_AT\tFINISHED_AT")
+	for _, info := range containersInfo {
+		id, command := info.Id, info.Command
+		if !noTrunc {
+			id = TruncateID(id)
+			if r := []rune(command); len(r) > maxCommandWidth {
+				command = string(r[:maxCommandWidth-3]) + "..."
+			}
+		}
+		fmt.Fprintf(tableWri, "%s\t%s\t%d\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			id, info.Name, info.Pid, command, info.StateString(), info.RestartCount, info.ExitCode, info.StartedAt, info.FinishedAt)
+	}
+	if err := tableWri.Flush(); err != nil {
+		logger.Error("flush error: ", err)
+		return err
+	}
+	return nil
+}
+
+// StateString 返回容器状态，运行中且配置了健康检查时附带健康状态，例如 running (healthy)
+func (info *Info) StateString() string {
+	if info.State == ContainerStateRunning && info.Health != nil {
+		return fmt.Sprintf("%s (%s)", info.State, info.Health.Status)
 	}
-	return &info, nil
+	return info.State
 }
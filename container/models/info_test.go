@@ -0,0 +1,77 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseContainerFilters(t *testing.T) {
+	args, err := ParseContainerFilters([]string{"status=exited", "status=running", "label=app", "label=tier=backend", "name=web"})
+	if err != nil {
+		t.Fatalf("ParseContainerFilters error: %v", err)
+	}
+	if want := []string{ContainerStateStopped, ContainerStateRunning}; !reflect.DeepEqual(args.Get("status"), want) {
+		t.Errorf("status = %q, want %q", args.Get("status"), want)
+	}
+	if want := []string{"app", "tier=backend"}; !reflect.DeepEqual(args.Get("label"), want) {
+		t.Errorf("label = %q, want %q", args.Get("label"), want)
+	}
+
+	for _, f := range []string{"status=dead", "status=Running", "id=abc", "volume=/data", "name"} {
+		if args, err := ParseContainerFilters([]string{f}); err == nil {
+			t.Errorf("ParseContainerFilters(%q) = %v, want error", f, args)
+		}
+	}
+}
+
+func TestFilterContainers(t *testing.T) {
+	infos := []Info{
+		{Id: "1", Name: "web-1", State: ContainerStateRunning, Image: "nginx", Network: "front", Labels: map[string]string{"app": "shop", "tier": "frontend"}},
+		{Id: "2", Name: "web-2", State: ContainerStatePaused, Image: "nginx", Network: "front", Labels: map[string]string{"app": "shop", "tier": "frontend"}},
+		{Id: "3", Name: "db", State: ContainerStateStopped, Image: "postgres", Network: "back", Labels: map[string]string{"app": "shop", "tier": "backend"}},
+		{Id: "4", Name: "job", State: ContainerStateCreated, Image: "busybox"},
+		{Id: "5", Name: "worker", State: ContainerStateRestarting, Image: "busybox", Labels: map[string]string{"app": "blog"}},
+	}
+	ids := func(all bool, filters []string) string {
+		t.Helper()
+		args, err := ParseContainerFilters(filters)
+		if err != nil {
+			t.Fatalf("ParseContainerFilters(%q) error: %v", filters, err)
+		}
+		var result []string
+		for _, info := range FilterContainers(infos, all, args) {
+			result = append(result, info.Id)
+		}
+		return strings.Join(result, ",")
+	}
+
+	checks := []struct {
+		all     bool
+		filters []string
+		want    string
+	}{
+		// 默认只列出运行中、暂停和重启中的容器
+		{false, nil, "1,2,5"},
+		{true, nil, "1,2,3,4,5"},
+		// 指定 status 时不需要 -a，exited 匹配 stopped
+		{false, []string{"status=exited"}, "3"},
+		{false, []string{"status=stopped"}, "3"},
+		// 同一个 key 的多个值取并集
+		{false, []string{"status=exited", "status=created"}, "3,4"},
+		{true, []string{"name=web", "name=job"}, "1,2,4"},
+		// 不同 key 取交集
+		{true, []string{"label=app=shop", "network=front"}, "1,2"},
+		{true, []string{"label=app=shop", "status=running"}, "1"},
+		{true, []string{"label=app"}, "1,2,3,5"},
+		{true, []string{"label=app=shop"}, "1,2,3"},
+		{true, []string{"label=app", "label=tier=backend"}, "3"},
+		{true, []string{"ancestor=busybox"}, "4,5"},
+		{true, []string{"name=nothing"}, ""},
+	}
+	for _, c := range checks {
+		if got := ids(c.all, c.filters); got != c.want {
+			t.Errorf("FilterContainers(all=%v, %q) = [%s], want [%s]", c.all, c.filters, got, c.want)
+		}
+	}
+}
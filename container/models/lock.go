@@ -0,0 +1,44 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// LockContainers 获取全局锁（容器根目录上的 flock），保护创建和重命名时的名称唯一性检查
+func LockContainers() (func(), error) {
+	if err := os.MkdirAll(DefaultContainerInfoPath, 0755); err != nil {
+		return nil, err
+	}
+	return flockPath(DefaultContainerInfoPath, os.O_RDONLY)
+}
+
+// LockContainer 获取容器的生命周期锁（容器目录上的 flock），start、stop、rm 等状态转换在持有该锁时进行，
+// 同一个容器上的并发命令因此依次执行。监控进程不获取该锁，只通过 UpdateContainerInfo 修改容器记录
+func LockContainer(containerID string) (func(), error) {
+	return flockPath(filepath.Join(DefaultContainerInfoPath, containerID), os.O_RDONLY)
+}
+
+// flockPath 打开 path 并加排他锁，返回解锁函数。文件以 O_CLOEXEC 打开，锁不会被子进程继承；
+// 同一进程内对同一路径重复加锁会阻塞
+func flockPath(path string, flag int) (func(), error) {
+	f, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phper95/tinydocker/pkg/logger"
+)
+
+// ReadProcessStartTime 读取 /proc/<pid>/stat 中进程的启动时间（系统启动后经过的时钟滴答数），
+// PID 被其他进程复用时启动时间一定不同，用来判断记录的 PID 是否还是容器进程
+func ReadProcessStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// 第2个字段是用括号包裹的进程名，可能包含空格，从最后一个')'之后开始解析
+	stat := string(data)
+	idx := strings.LastIndex(stat, ")")
+	if idx < 0 {
+		return 0, fmt.Errorf("invalid stat format for pid %d", pid)
+	}
+	// ')'之后从第3个字段(state)开始，starttime 是第22个字段
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("invalid stat format for pid %d", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// IsContainerProcessAlive 检查容器记录的 PID 是否仍然是容器的init进程
+func IsContainerProcessAlive(info *Info) bool {
+	return isProcessAlive(info.Pid, info.PidStartTime)
+}
+
+// IsMonitorProcessAlive 检查托管容器的监控进程是否仍在运行，旧版本没有记录监控进程时视为已退出
+func IsMonitorProcessAlive(info *Info) bool {
+	return isProcessAlive(info.MonitorPid, info.MonitorStartTime)
+}
+
+// isProcessAlive 检查 pid 是否仍然是启动时间为 startTime 的进程
+func isProcessAlive(pid int, startTime uint64) bool {
+	if pid <= 0 {
+		return false
+	}
+	actual, err := ReadProcessStartTime(pid)
+	if err != nil {
+		return false
+	}
+	// 旧版本没有记录启动时间，只能判断进程是否存在
+	return startTime == 0 || actual == startTime
+}
+
+// ReconcileContainerState 校验 running/paused 状态的容器进程、restarting 状态的监控进程是否还存在。
+// 宿主机重启或监控进程异常退出后记录中的状态不会更新，进程不存在（或 PID 已被复用）时将容器标记为已退出并写回数据库
+func ReconcileContainerState(info *Info) bool {
+	if !isProcessLost(info) {
+		return false
+	}
+	// 在事务内重新检查，监控进程可能刚刚记录了真实的退出状态
+	latest, err := UpdateContainerInfo(info.Id, func(latest *Info) bool {
+		if !isProcessLost(latest) {
+			return false
+		}
+		if latest.State == ContainerStateRestarting {
+			// 上次运行的退出状态已经记录，保留退出码，只是不会再重启
+			logger.Warn("container %s monitor process %d not found, marking it as stopped", latest.Id, latest.MonitorPid)
+			latest.State = ContainerStateStopped
+			latest.Error = "container monitor process not found, restart abandoned"
+			return true
+		}
+		logger.Warn("container %s process %d not found, marking it as stopped", latest.Id, latest.Pid)
+		latest.State = ContainerStateStopped
+		latest.FinishedAt = time.Now().Format(time.DateTime)
+		latest.ExitCode = -1
+		latest.Error = "container process not found, exit status unknown"
+		return true
+	})
+	if err != nil {
+		logger.Error("write container info error: ", err)
+		return false
+	}
+	*info = *latest
+	return true
+}
+
+// isProcessLost 容器记录为运行中但进程和监控进程都已经不存在，或者处于重启等待中但负责重启的监控进程已经不存在
+func isProcessLost(info *Info) bool {
+	switch info.State {
+	case ContainerStateRunning, ContainerStatePaused:
+		// 进程退出后监控进程先清理资源再写入 stopped，清理期间不能校正
+		return !IsContainerProcessAlive(info) && !IsMonitorProcessAlive(info)
+	case ContainerStateRestarting:
+		return !IsMonitorProcessAlive(info)
+	}
+	return false
+}
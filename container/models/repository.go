@@ -0,0 +1,542 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phper95/tinydocker/enum"
+	"github.com/phper95/tinydocker/pkg/db"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"go.etcd.io/bbolt"
+)
+
+// 容器数据库中的 bucket：containers 按ID保存完整记录，container_names 和 container_labels 是索引
+var (
+	containersBucket      = []byte("containers")
+	containerNamesBucket  = []byte("container_names")  // 名称 -> ID
+	containerLabelsBucket = []byte("container_labels") // key\x00value\x00ID -> 空
+	containerMetaBucket   = []byte("meta")
+	// containerExitsBucket 使用 --rm 的容器被自动删除前记录的退出状态，ID -> RemovedExit
+	containerExitsBucket = []byte("container_exits")
+	// migratedKey 记录旧版本的 config.json 文件已经导入数据库
+	migratedKey = []byte("config_json_migrated")
+)
+
+const (
+	// labelIndexSep 标签索引 key 中各部分的分隔符
+	labelIndexSep = "\x00"
+	// legacyContainerLockFileName 旧版本保护 config.json 读-改-写的锁文件，导入后一并删除
+	legacyContainerLockFileName = "config.lock"
+	// removedExitRetention 自动删除的容器的退出状态保留的时间，wait 在此期间仍然可以读到退出码
+	removedExitRetention = 10 * time.Minute
+)
+
+// 容器数据库文件和容器目录，测试时替换为临时目录
+var (
+	containerDBPath  = enum.DefaultContainerDBPath
+	containerInfoDir = DefaultContainerInfoPath
+)
+
+// 容器数据库连接。bbolt 使用文件锁，监控进程等长时间运行的进程也需要写入记录，
+// 因此每次操作时打开、操作结束后关闭；同一进程内的并发操作共用一个连接
+var (
+	repoLock     sync.Mutex
+	repoDB       *db.BoltDB
+	repoRefs     int
+	repoMigrated bool
+)
+
+// UseContainerRepository 改用 dbPath 的容器数据库和 infoDir 下的容器目录，返回恢复原设置的函数。
+// 用于测试，调用时不能有打开中的连接
+func UseContainerRepository(dbPath, infoDir string) (restore func()) {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	oldDBPath, oldInfoDir, oldMigrated := containerDBPath, containerInfoDir, repoMigrated
+	containerDBPath, containerInfoDir, repoMigrated = dbPath, infoDir, false
+	return func() {
+		repoLock.Lock()
+		defer repoLock.Unlock()
+		containerDBPath, containerInfoDir, repoMigrated = oldDBPath, oldInfoDir, oldMigrated
+	}
+}
+
+// openRepository 打开容器数据库并在首次打开时导入旧的 config.json，返回的函数用于释放连接
+func openRepository() (*db.BoltDB, func(), error) {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	if repoDB == nil {
+		if err := os.MkdirAll(filepath.Dir(containerDBPath), 0755); err != nil {
+			return nil, nil, err
+		}
+		boltDB, err := db.NewBoltDB(containerDBPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open container db error: %v", err)
+		}
+		if !repoMigrated {
+			if err := migrateContainerInfoFiles(boltDB); err != nil {
+				boltDB.Close()
+				return nil, nil, err
+			}
+			repoMigrated = true
+		}
+		repoDB = boltDB
+	}
+	repoRefs++
+	return repoDB, sync.OnceFunc(releaseRepository), nil
+}
+
+// releaseRepository 释放一次 openRepository 的引用，没有引用时关闭数据库
+func releaseRepository() {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	repoRefs--
+	if repoRefs > 0 {
+		return
+	}
+	if err := repoDB.Close(); err != nil {
+		logger.Error("close container db error: ", err)
+	}
+	repoDB = nil
+}
+
+// viewRepository 在只读事务中执行 fn
+func viewRepository(fn func(tx *bbolt.Tx) error) error {
+	boltDB, release, err := openRepository()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return boltDB.View(fn)
+}
+
+// updateRepository 在读写事务中执行 fn，fn 中不能再调用其他读写容器记录的函数
+func updateRepository(fn func(tx *bbolt.Tx) error) error {
+	boltDB, release, err := openRepository()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return boltDB.Update(fn)
+}
+
+// errContainerNotExist 容器记录不存在，可以用 errors.Is(err, os.ErrNotExist) 判断
+func errContainerNotExist(ref string) error {
+	return fmt.Errorf("container %s: %w", ref, os.ErrNotExist)
+}
+
+// GetContainerInfo 按完整ID读取容器记录，不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func GetContainerInfo(containerID string) (*Info, error) {
+	var info *Info
+	err := viewRepository(func(tx *bbolt.Tx) error {
+		var err error
+		info, err = getContainer(tx, containerID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errContainerNotExist(containerID)
+	}
+	return info, nil
+}
+
+// GetContainerInfoByName 通过名称索引读取容器记录，不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func GetContainerInfoByName(name string) (*Info, error) {
+	var info *Info
+	err := viewRepository(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(containerNamesBucket)
+		if b == nil {
+			return nil
+		}
+		id := b.Get([]byte(name))
+		if id == nil {
+			return nil
+		}
+		var err error
+		info, err = getContainer(tx, string(id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errContainerNotExist(name)
+	}
+	return info, nil
+}
+
+// FindContainersByIDPrefix 返回ID以 prefix 开头的所有容器记录
+func FindContainersByIDPrefix(prefix string) ([]Info, error) {
+	var infos []Info
+	err := viewRepository(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(containersBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			var info Info
+			if err := json.Unmarshal(v, &info); err != nil {
+				logger.Error("unmarshal container %s error: %v", k, err)
+				continue
+			}
+			infos = append(infos, info)
+		}
+		return nil
+	})
+	return infos, err
+}
+
+// ReadContainersInfo 在一个只读事务中读取所有容器记录，并校正进程已经不存在的容器状态
+func ReadContainersInfo() []Info {
+	return ReadContainersInfoByLabels(nil)
+}
+
+// ReadContainersInfoByLabels 通过标签索引读取满足所有标签条件的容器记录，条件为 key 或 key=value，
+// 没有条件时返回所有容器
+func ReadContainersInfoByLabels(labels []string) []Info {
+	var infos []Info
+	err := viewRepository(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(containersBucket)
+		if b == nil {
+			return nil
+		}
+		if len(labels) == 0 {
+			return b.ForEach(func(k, v []byte) error {
+				var info Info
+				if err := json.Unmarshal(v, &info); err != nil {
+					logger.Error("unmarshal container %s error: %v", k, err)
+					return nil
+				}
+				infos = append(infos, info)
+				return nil
+			})
+		}
+		ids := containerIDsByLabel(tx, labels[0])
+		for _, label := range labels[1:] {
+			matched := containerIDsByLabel(tx, label)
+			for id := range ids {
+				if !matched[id] {
+					delete(ids, id)
+				}
+			}
+		}
+		sorted := make([]string, 0, len(ids))
+		for id := range ids {
+			sorted = append(sorted, id)
+		}
+		// 与遍历 containers bucket 的结果一样按ID排序
+		sort.Strings(sorted)
+		for _, id := range sorted {
+			info, err := getContainer(tx, id)
+			if err != nil {
+				logger.Error("read container %s error: %v", id, err)
+				continue
+			}
+			if info != nil {
+				infos = append(infos, *info)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("read containers error: ", err)
+		return nil
+	}
+	// 校正状态需要写入数据库，在读事务结束后进行
+	for i := range infos {
+		ReconcileContainerState(&infos[i])
+	}
+	return infos
+}
+
+// containerIDsByLabel 通过标签索引返回满足 key 或 key=value 条件的容器ID
+func containerIDsByLabel(tx *bbolt.Tx, label string) map[string]bool {
+	ids := make(map[string]bool)
+	b := tx.Bucket(containerLabelsBucket)
+	if b == nil {
+		return ids
+	}
+	key, value, hasValue := strings.Cut(label, "=")
+	prefix := key + labelIndexSep
+	if hasValue {
+		prefix += value + labelIndexSep
+	}
+	c := b.Cursor()
+	for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+		// ID 是最后一部分
+		parts := strings.Split(string(k), labelIndexSep)
+		ids[parts[len(parts)-1]] = true
+	}
+	return ids
+}
+
+// WriteContainerInfo 保存容器的完整记录并更新名称和标签索引，需要基于最新记录修改部分字段时使用 UpdateContainerInfo。
+// 容器目录同时存放日志、overlay 层并作为生命周期锁，在这里确保它存在
+func WriteContainerInfo(info *Info) error {
+	dirPath := filepath.Join(containerInfoDir, info.Id)
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		logger.Error("mkdirall error: ", err)
+		return err
+	}
+	return updateRepository(func(tx *bbolt.Tx) error {
+		old, err := getContainer(tx, info.Id)
+		if err != nil {
+			return err
+		}
+		return putContainer(tx, old, info)
+	})
+}
+
+// UpdateContainerInfo 在一个读写事务中读取最新记录并调用 update 修改，update 返回 false 时不写回。
+// 所有读-改-写都通过它进行，避免并发的命令和监控进程互相覆盖修改；update 中不能再读写容器记录
+func UpdateContainerInfo(containerID string, update func(info *Info) bool) (*Info, error) {
+	var info *Info
+	err := updateRepository(func(tx *bbolt.Tx) error {
+		old, err := getContainer(tx, containerID)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			return errContainerNotExist(containerID)
+		}
+		// update 修改的是副本，old 用于删除旧的索引
+		info, err = getContainer(tx, containerID)
+		if err != nil {
+			return err
+		}
+		if !update(info) {
+			return nil
+		}
+		return putContainer(tx, old, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DeleteContainerInfo 删除容器记录及其索引，记录不存在时不报错
+func DeleteContainerInfo(containerID string) error {
+	return updateRepository(func(tx *bbolt.Tx) error {
+		old, err := getContainer(tx, containerID)
+		if err != nil || old == nil {
+			return err
+		}
+		deleteContainerIndexes(tx, old)
+		return tx.Bucket(containersBucket).Delete([]byte(containerID))
+	})
+}
+
+// RemovedExit 使用 --rm 的容器被自动删除前记录的退出状态
+type RemovedExit struct {
+	ExitCode   int   `json:"exit_code"`
+	FinishedAt int64 `json:"finished_at"` // Unix 时间戳，用于清理过期的记录
+}
+
+// SaveRemovedExit 在自动删除容器前记录退出码，供删除后才读取的 wait 使用，同时清理过期的记录
+func SaveRemovedExit(containerID string, exitCode int) error {
+	now := time.Now()
+	data, err := json.Marshal(RemovedExit{ExitCode: exitCode, FinishedAt: now.Unix()})
+	if err != nil {
+		return err
+	}
+	return updateRepository(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(containerExitsBucket)
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		err = b.ForEach(func(k, v []byte) error {
+			var exit RemovedExit
+			if json.Unmarshal(v, &exit) != nil || now.Sub(time.Unix(exit.FinishedAt, 0)) > removedExitRetention {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(containerID), data)
+	})
+}
+
+// GetRemovedExit 读取被自动删除的容器的退出码，没有记录时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func GetRemovedExit(containerID string) (int, error) {
+	var exit *RemovedExit
+	err := viewRepository(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(containerExitsBucket)
+		if b == nil {
+			return nil
+		}
+		data := b.Get([]byte(containerID))
+		if data == nil {
+			return nil
+		}
+		exit = &RemovedExit{}
+		return json.Unmarshal(data, exit)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if exit == nil {
+		return 0, errContainerNotExist(containerID)
+	}
+	return exit.ExitCode, nil
+}
+
+// getContainer 在事务中按ID读取容器记录，不存在时返回 nil
+func getContainer(tx *bbolt.Tx, containerID string) (*Info, error) {
+	b := tx.Bucket(containersBucket)
+	if b == nil {
+		return nil, nil
+	}
+	data := b.Get([]byte(containerID))
+	if data == nil {
+		return nil, nil
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("unmarshal container %s error: %v", containerID, err)
+	}
+	return &info, nil
+}
+
+// putContainer 在事务中写入容器记录，old 为写入前的记录（可以为 nil），用于替换旧的索引
+func putContainer(tx *bbolt.Tx, old, info *Info) error {
+	names, err := tx.CreateBucketIfNotExists(containerNamesBucket)
+	if err != nil {
+		return err
+	}
+	if id := names.Get([]byte(info.Name)); id != nil && string(id) != info.Id {
+		return fmt.Errorf("container name %q is already in use by container %s", info.Name, TruncateID(string(id)))
+	}
+	if old != nil {
+		deleteContainerIndexes(tx, old)
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	containers, err := tx.CreateBucketIfNotExists(containersBucket)
+	if err != nil {
+		return err
+	}
+	if err := containers.Put([]byte(info.Id), data); err != nil {
+		return err
+	}
+	if info.Name != "" {
+		if err := names.Put([]byte(info.Name), []byte(info.Id)); err != nil {
+			return err
+		}
+	}
+	labels, err := tx.CreateBucketIfNotExists(containerLabelsBucket)
+	if err != nil {
+		return err
+	}
+	for k, v := range info.Labels {
+		if err := labels.Put(labelIndexKey(k, v, info.Id), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteContainerIndexes 删除容器的名称和标签索引
+func deleteContainerIndexes(tx *bbolt.Tx, info *Info) {
+	if b := tx.Bucket(containerNamesBucket); b != nil {
+		if id := b.Get([]byte(info.Name)); string(id) == info.Id {
+			b.Delete([]byte(info.Name))
+		}
+	}
+	if b := tx.Bucket(containerLabelsBucket); b != nil {
+		for k, v := range info.Labels {
+			b.Delete(labelIndexKey(k, v, info.Id))
+		}
+	}
+}
+
+// labelIndexKey 返回标签索引的 key，按 key、value 排序后可以用前缀查找
+func labelIndexKey(key, value, containerID string) []byte {
+	return []byte(key + labelIndexSep + value + labelIndexSep + containerID)
+}
+
+// migrateContainerInfoFiles 将旧版本保存在容器目录下的 config.json 导入数据库，只执行一次。
+// 导入在一个事务中完成，提交后删除旧文件
+func migrateContainerInfoFiles(boltDB *db.BoltDB) error {
+	var files []string
+	err := boltDB.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(containerMetaBucket)
+		if err != nil {
+			return err
+		}
+		if meta.Get(migratedKey) != nil {
+			return nil
+		}
+		dirs, err := os.ReadDir(containerInfoDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		for _, d := range dirs {
+			if !d.IsDir() {
+				continue
+			}
+			file := filepath.Join(containerInfoDir, d.Name(), DefaultContainerInfoFileName)
+			info, err := readContainerInfoFile(file)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					logger.Warn("skip container %s: %v", d.Name(), err)
+				}
+				continue
+			}
+			old, err := getContainer(tx, info.Id)
+			if err != nil {
+				return err
+			}
+			if err := putContainer(tx, old, info); err != nil {
+				logger.Warn("skip container %s: %v", d.Name(), err)
+				continue
+			}
+			files = append(files, file)
+		}
+		if len(files) > 0 {
+			logger.Info("Migrated %d containers from %s", len(files), containerInfoDir)
+		}
+		return meta.Put(migratedKey, []byte("1"))
+	})
+	if err != nil {
+		return fmt.Errorf("migrate container info error: %v", err)
+	}
+	for _, file := range files {
+		os.Remove(file)
+		os.Remove(filepath.Join(filepath.Dir(file), legacyContainerLockFileName))
+	}
+	return nil
+}
+
+// readContainerInfoFile 读取旧版本的 config.json
+func readContainerInfoFile(filePath string) (*Info, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// useTempRepository 将容器数据库和容器目录替换为临时目录，返回容器目录
+func useTempRepository(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Cleanup(UseContainerRepository(filepath.Join(dir, "containers.db"), filepath.Join(dir, "containers")))
+	return containerInfoDir
+}
+
+// writeLegacyInfo 按旧版本的格式在容器目录下写入 config.json
+func writeLegacyInfo(t *testing.T, infoDir string, info *Info) string {
+	t.Helper()
+	dir := filepath.Join(infoDir, info.Id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, DefaultContainerInfoFileName)
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func containerIDs(infos []Info) []string {
+	ids := make([]string, 0, len(infos))
+	for _, info := range infos {
+		ids = append(ids, info.Id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestMigrateContainerInfoFiles(t *testing.T) {
+	infoDir := useTempRepository(t)
+	web := writeLegacyInfo(t, infoDir, &Info{Id: "aaaa1111", Name: "web", State: ContainerStateStopped, Labels: map[string]string{"app": "web"}})
+	db := writeLegacyInfo(t, infoDir, &Info{Id: "bbbb2222", Name: "db", State: ContainerStateStopped})
+	// 与已导入的容器重名的记录被跳过，文件保留
+	dup := writeLegacyInfo(t, infoDir, &Info{Id: "cccc3333", Name: "web", State: ContainerStateStopped})
+	if err := os.WriteFile(filepath.Join(infoDir, "aaaa1111", legacyContainerLockFileName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := GetContainerInfoByName("web")
+	if err != nil {
+		t.Fatalf("GetContainerInfoByName after migration: %v", err)
+	}
+	if info.Id != "aaaa1111" || info.Labels["app"] != "web" {
+		t.Errorf("migrated web = %+v", info)
+	}
+	if got := containerIDs(ReadContainersInfo()); strings.Join(got, ",") != "aaaa1111,bbbb2222" {
+		t.Errorf("migrated containers = %q", got)
+	}
+	for _, file := range []string{web, db, filepath.Join(infoDir, "aaaa1111", legacyContainerLockFileName)} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after migration: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(dup); err != nil {
+		t.Errorf("skipped %s was removed: %v", dup, err)
+	}
+
+	// 模拟新的进程重新打开数据库：已经导入过，之后出现的 config.json 不会再导入
+	repoMigrated = false
+	writeLegacyInfo(t, infoDir, &Info{Id: "dddd4444", Name: "late", State: ContainerStateStopped})
+	if got := containerIDs(ReadContainersInfo()); strings.Join(got, ",") != "aaaa1111,bbbb2222" {
+		t.Errorf("containers after re-open = %q", got)
+	}
+	if _, err := GetContainerInfo("dddd4444"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetContainerInfo(dddd4444) error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestContainerNameUnique(t *testing.T) {
+	useTempRepository(t)
+	if err := WriteContainerInfo(&Info{Id: "aaaa1111", Name: "web", State: ContainerStateStopped}); err != nil {
+		t.Fatal(err)
+	}
+	err := WriteContainerInfo(&Info{Id: "bbbb2222", Name: "web", State: ContainerStateStopped})
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Fatalf("writing a duplicate name: err = %v, want already in use", err)
+	}
+	if _, err := GetContainerInfo("bbbb2222"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("rejected container was saved: %v", err)
+	}
+
+	// 改名后旧名称可以被其他容器使用，新名称指向原来的容器
+	if _, err := UpdateContainerInfo("aaaa1111", func(info *Info) bool {
+		info.Name = "web-old"
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteContainerInfo(&Info{Id: "bbbb2222", Name: "web", State: ContainerStateStopped}); err != nil {
+		t.Fatalf("reusing a released name: %v", err)
+	}
+	for name, id := range map[string]string{"web": "bbbb2222", "web-old": "aaaa1111"} {
+		if info, err := GetContainerInfoByName(name); err != nil || info.Id != id {
+			t.Errorf("GetContainerInfoByName(%q) = %v, %v, want %s", name, info, err, id)
+		}
+	}
+	// 更新自己的记录不会与自己的名称冲突
+	if _, err := UpdateContainerInfo("bbbb2222", func(info *Info) bool { return true }); err != nil {
+		t.Errorf("updating a container without renaming: %v", err)
+	}
+}
+
+func TestContainerLabelIndex(t *testing.T) {
+	useTempRepository(t)
+	containers := []*Info{
+		{Id: "aaaa1111", Name: "web", Labels: map[string]string{"app": "shop", "tier": "frontend"}},
+		{Id: "bbbb2222", Name: "db", Labels: map[string]string{"app": "shop", "tier": "backend"}},
+		{Id: "cccc3333", Name: "tmp"},
+	}
+	for _, info := range containers {
+		info.State = ContainerStateStopped
+		if err := WriteContainerInfo(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(labels []string, want string) {
+		t.Helper()
+		if got := strings.Join(containerIDs(ReadContainersInfoByLabels(labels)), ","); got != want {
+			t.Errorf("ReadContainersInfoByLabels(%q) = %s, want %s", labels, got, want)
+		}
+	}
+	check([]string{"app"}, "aaaa1111,bbbb2222")
+	check([]string{"app=shop", "tier=backend"}, "bbbb2222")
+	check([]string{"tier=frontend"}, "aaaa1111")
+	check(nil, "aaaa1111,bbbb2222,cccc3333")
+
+	// 修改标签后旧的索引被删除
+	if _, err := UpdateContainerInfo("aaaa1111", func(info *Info) bool {
+		info.Labels = map[string]string{"app": "blog"}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	check([]string{"app=shop"}, "bbbb2222")
+	check([]string{"tier"}, "bbbb2222")
+	check([]string{"app=blog"}, "aaaa1111")
+
+	if err := DeleteContainerInfo("bbbb2222"); err != nil {
+		t.Fatal(err)
+	}
+	check([]string{"app"}, "aaaa1111")
+	check([]string{"tier"}, "")
+	// 删除后名称也可以重新使用
+	if err := WriteContainerInfo(&Info{Id: "dddd4444", Name: "db", State: ContainerStateStopped}); err != nil {
+		t.Errorf("reusing the name of a deleted container: %v", err)
+	}
+}
+
+func TestRemovedExit(t *testing.T) {
+	useTempRepository(t)
+	if _, err := GetRemovedExit("aaaa1111"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetRemovedExit before save error = %v, want os.ErrNotExist", err)
+	}
+	if err := SaveRemovedExit("aaaa1111", 137); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := GetRemovedExit("aaaa1111"); err != nil || code != 137 {
+		t.Errorf("GetRemovedExit = %d, %v, want 137", code, err)
+	}
+
+	// 过期的记录在下一次保存时被清理
+	expired, _ := json.Marshal(RemovedExit{ExitCode: 1, FinishedAt: time.Now().Add(-removedExitRetention - time.Minute).Unix()})
+	if err := updateRepository(func(tx *bbolt.Tx) error {
+		return tx.Bucket(containerExitsBucket).Put([]byte("bbbb2222"), expired)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := GetRemovedExit("bbbb2222"); err != nil || code != 1 {
+		t.Fatalf("GetRemovedExit(expired) before cleanup = %d, %v", code, err)
+	}
+	if err := SaveRemovedExit("cccc3333", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetRemovedExit("bbbb2222"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired exit still present: %v", err)
+	}
+	if code, err := GetRemovedExit("aaaa1111"); err != nil || code != 137 {
+		t.Errorf("GetRemovedExit(aaaa1111) after cleanup = %d, %v, want 137", code, err)
+	}
+}
@@ -0,0 +1,44 @@
+package container
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+// 自动生成容器名称使用的词表，生成的名称形如 brave_turing
+var (
+	nameAdjectives = []string{
+		"admiring", "amazing", "awesome", "bold", "brave", "busy", "calm", "clever",
+		"cool", "eager", "elegant", "epic", "focused", "friendly", "gallant", "happy",
+		"hopeful", "jolly", "keen", "kind", "lucid", "modest", "nice", "nifty",
+		"optimistic", "peaceful", "quirky", "relaxed", "serene", "sharp", "stoic", "vibrant",
+	}
+	nameSurnames = []string{
+		"babbage", "bell", "curie", "darwin", "dijkstra", "einstein", "euler", "faraday",
+		"fermat", "feynman", "galileo", "gauss", "hopper", "kepler", "knuth", "lamport",
+		"lovelace", "maxwell", "mendel", "newton", "noether", "pascal", "pike", "ritchie",
+		"shannon", "tesla", "thompson", "torvalds", "turing", "wozniak", "wright", "zhukovsky",
+	}
+)
+
+// maxNameAttempts 自动生成名称时的最大尝试次数，超过后在名称后追加随机数字
+const maxNameAttempts = 10
+
+// generateContainerName 生成一个未被使用的容器名称
+func generateContainerName() string {
+	used := make(map[string]bool)
+	for _, info := range models.ReadContainersInfo() {
+		used[info.Name] = true
+	}
+	for i := 0; ; i++ {
+		name := nameAdjectives[rand.Intn(len(nameAdjectives))] + "_" + nameSurnames[rand.Intn(len(nameSurnames))]
+		if i >= maxNameAttempts {
+			name = fmt.Sprintf("%s%d", name, rand.Intn(100))
+		}
+		if !used[name] {
+			return name
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/oci"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// execFifoName OCI 容器 create 之后，init 进程阻塞在该 FIFO 上等待 start
+	execFifoName = "exec.fifo"
+	// execFifoTimeout start 等待 init 进程打开 FIFO 的最长时间
+	execFifoTimeout = 10 * time.Second
+)
+
+// containerIDPattern OCI 容器ID允许的字符，ID 会作为目录名和 cgroup 名使用
+var containerIDPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ociMountFlags OCI 挂载选项对应的挂载标志，clear 为 true 表示清除该标志
+var ociMountFlags = map[string]struct {
+	clear bool
+	flag  uintptr
+}{
+	"ro":          {false, syscall.MS_RDONLY},
+	"rw":          {true, syscall.MS_RDONLY},
+	"nosuid":      {false, syscall.MS_NOSUID},
+	"suid":        {true, syscall.MS_NOSUID},
+	"nodev":       {false, syscall.MS_NODEV},
+	"dev":         {true, syscall.MS_NODEV},
+	"noexec":      {false, syscall.MS_NOEXEC},
+	"exec":        {true, syscall.MS_NOEXEC},
+	"sync":        {false, syscall.MS_SYNCHRONOUS},
+	"async":       {true, syscall.MS_SYNCHRONOUS},
+	"noatime":     {false, syscall.MS_NOATIME},
+	"atime":       {true, syscall.MS_NOATIME},
+	"relatime":    {false, syscall.MS_RELATIME},
+	"strictatime": {false, syscall.MS_STRICTATIME},
+	"bind":        {false, syscall.MS_BIND},
+	"rbind":       {false, syscall.MS_BIND | syscall.MS_REC},
+	"defaults":    {false, 0},
+}
+
+// CreateFromBundle 按照 OCI runtime-spec 的 create 语义创建容器：
+// 启动 init 进程并完成 namespace、根文件系统等配置，但不执行用户进程，直到调用 Start
+func CreateFromBundle(containerID, bundle string) error {
+	if !containerIDPattern.MatchString(containerID) {
+		return fmt.Errorf("invalid container id %q", containerID)
+	}
+	unlock, err := models.LockContainers()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := models.GetContainerInfo(containerID); err == nil {
+		return fmt.Errorf("container %s already exists", containerID)
+	}
+	// 容器ID同时作为名称使用
+	if err := checkNameAvailable(containerID); err != nil {
+		return err
+	}
+	bundle, err = filepath.Abs(bundle)
+	if err != nil {
+		return err
+	}
+	spec, err := oci.LoadSpec(bundle)
+	if err != nil {
+		return err
+	}
+	rootfs := spec.RootfsPath(bundle)
+	if fi, err := os.Stat(rootfs); err != nil || !fi.IsDir() {
+		return fmt.Errorf("rootfs %s is not a directory", rootfs)
+	}
+
+	info := &models.Info{
+		Id:            containerID,
+		Name:          containerID,
+		Command:       strings.Join(spec.Process.Args, " "),
+		State:         models.ContainerStateCreated,
+		CreatedAt:     time.Now().Format(time.DateTime),
+		Args:          spec.Process.Args,
+		Env:           spec.Process.Env,
+		Detach:        true,
+		Bundle:        bundle,
+		Rootfs:        rootfs,
+		RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyNo},
+		Hooks:         spec.Hooks,
+	}
+	if spec.Linux != nil && spec.Linux.Resources != nil {
+		res := spec.Linux.Resources
+		if res.Memory != nil && res.Memory.Limit != nil && *res.Memory.Limit > 0 {
+			info.MemoryLimit = strconv.FormatInt(*res.Memory.Limit, 10)
+		}
+		if res.CPU != nil && res.CPU.Quota != nil && res.CPU.Period != nil && *res.CPU.Quota > 0 && *res.CPU.Period > 0 {
+			info.CpuLimit = strconv.FormatFloat(float64(*res.CPU.Quota)/float64(*res.CPU.Period), 'f', -1, 64)
+		}
+	}
+
+	if err := models.WriteContainerInfo(info); err != nil {
+		return err
+	}
+	if err := syscall.Mkfifo(execFifoPath(containerID), 0622); err != nil {
+		removeContainerDir(containerID)
+		return fmt.Errorf("create exec fifo error: %v", err)
+	}
+	// 由监控进程持有 init 进程，create 返回后 init 进程阻塞在 exec.fifo 上
+	if err := spawnShim(info); err != nil {
+		removeContainerDir(containerID)
+		return err
+	}
+	logger.Info("Container %s created from bundle %s", containerID, bundle)
+	return nil
+}
+
+// startBundleContainer 打开 exec.fifo 解除 init 进程的阻塞，init 进程随后 exec 用户进程
+func startBundleContainer(info *models.Info) error {
+	if info.State != models.ContainerStateCreated || info.Pid == 0 {
+		return fmt.Errorf("container %s is not in created state", info.Id)
+	}
+	if !models.IsContainerProcessAlive(info) {
+		return fmt.Errorf("container %s init process is not running", info.Id)
+	}
+
+	// 先更新状态，避免用户进程很快退出时监控进程写入的 stopped 被覆盖
+	info.State = models.ContainerStateRunning
+	info.StartedAt = time.Now().Format(time.DateTime)
+	_, err := models.UpdateContainerInfo(info.Id, func(latest *models.Info) bool {
+		latest.State = info.State
+		latest.StartedAt = info.StartedAt
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	fifo := execFifoPath(info.Id)
+	done := make(chan error, 1)
+	go func() {
+		// 只读打开 FIFO 会阻塞到 init 进程以写方式打开
+		f, err := os.OpenFile(fifo, os.O_RDONLY, 0)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer f.Close()
+		_, err = io.ReadFull(f, make([]byte, 1))
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(execFifoTimeout):
+		err = errors.New("timeout waiting for init process")
+	}
+	if err != nil {
+		// init 进程无法继续，结束它，由监控进程记录退出状态
+		syscall.Kill(info.Pid, syscall.SIGKILL)
+		return fmt.Errorf("start container %s error: %v", info.Id, err)
+	}
+	if err := os.Remove(fifo); err != nil {
+		logger.Warn("Failed to remove exec fifo: %v", err)
+	}
+	if err := runHooks(info, HookPoststart); err != nil {
+		logger.Warn("poststart hooks of container %s failed: %v", info.Id, err)
+	}
+	logger.Info("Container %s started", info.Id)
+	return nil
+}
+
+// RuntimeStart 按完整ID启动 create 创建的容器，供 runtime start 使用
+func RuntimeStart(containerID string) error {
+	info, unlock, err := lockContainerByID(containerID)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerID, err)
+	}
+	return start(containerID, info, sync.OnceFunc(unlock))
+}
+
+// RuntimeKill 按完整ID向容器的init进程发送信号，供 runtime kill 使用
+func RuntimeKill(containerID string, sig syscall.Signal) error {
+	info, unlock, err := lockContainerByID(containerID)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerID, err)
+	}
+	defer unlock()
+	return kill(containerID, info, sig)
+}
+
+// RuntimeDelete 按完整ID删除容器，供 runtime delete 使用
+func RuntimeDelete(containerID string, force bool) error {
+	info, unlock, err := lockContainerByID(containerID)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerID, err)
+	}
+	defer unlock()
+	return remove(containerID, info, force)
+}
+
+// State 返回 OCI runtime-spec 定义的容器状态
+func State(containerID string) (*oci.State, error) {
+	info, err := GetContainerByID(containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find container %s: %v", containerID, err)
+	}
+	state := ociState(info)
+	if info.Bundle != "" {
+		// bundle 可能已被删除，此时只是缺少 annotations
+		if spec, err := oci.LoadSpec(info.Bundle); err == nil {
+			state.Annotations = spec.Annotations
+		}
+	}
+	return state, nil
+}
+
+// ociState 将容器记录转换为 OCI 定义的容器状态
+func ociState(info *models.Info) *oci.State {
+	state := &oci.State{
+		Version: oci.Version,
+		ID:      info.Id,
+		Bundle:  info.Bundle,
+	}
+	switch info.State {
+	case models.ContainerStateCreated:
+		if info.Pid == 0 {
+			state.Status = oci.StateCreating
+		} else {
+			state.Status = oci.StateCreated
+			state.Pid = info.Pid
+		}
+	case models.ContainerStateRunning, models.ContainerStatePaused, models.ContainerStateRestarting:
+		state.Status = oci.StateRunning
+		state.Pid = info.Pid
+	default:
+		state.Status = oci.StateStopped
+	}
+	return state
+}
+
+// execFifoPath 返回容器 exec.fifo 的路径
+func execFifoPath(containerID string) string {
+	return filepath.Join(models.DefaultContainerInfoPath, containerID, execFifoName)
+}
+
+// containerRootfs 返回容器根文件系统所在目录，OCI bundle 容器直接使用 bundle 中的 rootfs
+func containerRootfs(info *models.Info) string {
+	if info.Bundle != "" {
+		return info.Rootfs
+	}
+	return GetContainerMountPoint(info.Id)
+}
+
+// newBundleInitSpec 根据 bundle 中的 config.json 生成 init 进程的启动规格
+func newBundleInitSpec(info *models.Info) (*InitSpec, error) {
+	spec, err := oci.LoadSpec(info.Bundle)
+	if err != nil {
+		return nil, err
+	}
+	proc := spec.Process
+	initSpec := &InitSpec{
+		Version:        InitSpecVersion,
+		Args:           proc.Args,
+		Env:            proc.Env,
+		Cwd:            proc.Cwd,
+		User:           fmt.Sprintf("%d:%d", proc.User.UID, proc.User.GID),
+		AdditionalGids: proc.User.AdditionalGids,
+		Hostname:       spec.Hostname,
+		ReadonlyRootfs: spec.Root.Readonly,
+		DefaultDevices: true,
+		ExecFifo:       execFifoPath(info.Id),
+	}
+	for _, rl := range proc.Rlimits {
+		initSpec.Rlimits = append(initSpec.Rlimits, Rlimit{Type: rl.Type, Soft: rl.Soft, Hard: rl.Hard})
+	}
+	if len(spec.Mounts) == 0 {
+		initSpec.Mounts = defaultMounts
+	}
+	for _, m := range spec.Mounts {
+		// cgroup v1 挂载在 cgroup v2 的宿主机上无法使用
+		if m.Type == "cgroup" {
+			logger.Warn("skip unsupported cgroup mount %s", m.Destination)
+			continue
+		}
+		initSpec.Mounts = append(initSpec.Mounts, newOCIMountSpec(m))
+	}
+	return initSpec, nil
+}
+
+// newOCIMountSpec 将 OCI 挂载选项转换为挂载标志，无法识别的选项作为挂载数据传给文件系统
+func newOCIMountSpec(m oci.Mount) MountSpec {
+	mount := MountSpec{Source: m.Source, Target: m.Destination, Type: m.Type}
+	var data []string
+	for _, opt := range m.Options {
+		f, ok := ociMountFlags[opt]
+		if !ok {
+			data = append(data, opt)
+			continue
+		}
+		if f.clear {
+			mount.Flags &^= f.flag
+		} else {
+			mount.Flags |= f.flag
+		}
+	}
+	mount.Data = strings.Join(data, ",")
+	return mount
+}
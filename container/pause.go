@@ -0,0 +1,55 @@
+package container
+
+import (
+	"fmt"
+	"github.com/phper95/tinydocker/cgroups"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/pkg/logger"
+)
+
+// Pause 通过 cgroup freezer 冻结容器内的所有进程，容器文件系统在冻结期间保持一致，便于做快照
+func Pause(containerName string) error {
+	info, unlock, err := lockContainer(containerName)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %w", containerName, err)
+	}
+	defer unlock()
+	if info.State == models.ContainerStatePaused {
+		return errInvalidState("container %s is already paused", containerName)
+	}
+	if info.State != models.ContainerStateRunning {
+		return errInvalidState("container %s is not running", containerName)
+	}
+
+	if err := cgroups.SetFrozen(cgroups.ContainerCgroupName(info.Id), true); err != nil {
+		logger.Error("Failed to freeze container %s: %v", containerName, err)
+		return fmt.Errorf("failed to pause container %s: %v", containerName, err)
+	}
+	if err := models.UpdateContainerState(info.Id, models.ContainerStatePaused); err != nil {
+		return fmt.Errorf("failed to update container %s state: %v", containerName, err)
+	}
+	logger.Info("Container %s paused", containerName)
+	return nil
+}
+
+// Unpause 解冻被 Pause 冻结的容器
+func Unpause(containerName string) error {
+	info, unlock, err := lockContainer(containerName)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %w", containerName, err)
+	}
+	defer unlock()
+	if info.State != models.ContainerStatePaused {
+		return errInvalidState("container %s is not paused", containerName)
+	}
+
+	if err := cgroups.SetFrozen(cgroups.ContainerCgroupName(info.Id), false); err != nil {
+		logger.Error("Failed to thaw container %s: %v", containerName, err)
+		return fmt.Errorf("failed to unpause container %s: %v", containerName, err)
+	}
+	if err := models.UpdateContainerState(info.Id, models.ContainerStateRunning); err != nil {
+		return fmt.Errorf("failed to update container %s state: %v", containerName, err)
+	}
+	logger.Info("Container %s unpaused", containerName)
+	return nil
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"fmt"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// runReaper 是 --init 模式下容器的 PID 1：启动用户进程，将收到的信号转发给用户进程所在的进程组，
+// 回收容器内的孤儿进程，用户进程退出后以相同的退出码退出（被信号终止时为 128+信号值）
+func runReaper(path string, args, env []string) error {
+	// 在启动用户进程之前注册，避免错过用户进程很快退出时的 SIGCHLD
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals)
+
+	sys := &syscall.SysProcAttr{Setpgid: true}
+	// 交互模式下用户进程需要成为终端的前台进程组才能读取输入
+	if isTerminal(0) {
+		sys.Foreground = true
+		sys.Ctty = 0
+	}
+	child, err := os.StartProcess(path, args, &os.ProcAttr{
+		Env:   env,
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		Sys:   sys,
+	})
+	if err != nil {
+		logger.Error("Failed to start user process: ", err)
+		return err
+	}
+
+	for sig := range signals {
+		s, ok := sig.(syscall.Signal)
+		// SIGURG 由 Go 运行时用于抢占调度，不转发
+		if !ok || s == syscall.SIGURG {
+			continue
+		}
+		if s != syscall.SIGCHLD {
+			if err := syscall.Kill(-child.Pid, s); err != nil && err != syscall.ESRCH {
+				logger.Error("Failed to forward signal %s: %v", SignalName(s), err)
+			}
+			continue
+		}
+		// SIGCHLD 会合并，每次都回收所有已退出的子进程
+		for {
+			var ws syscall.WaitStatus
+			pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+			if err != nil || pid <= 0 {
+				break
+			}
+			if pid != child.Pid {
+				continue
+			}
+			// PID 1 退出后内核会结束容器内的其他进程
+			if ws.Signaled() {
+				os.Exit(128 + int(ws.Signal()))
+			}
+			os.Exit(ws.ExitStatus())
+		}
+	}
+	return fmt.Errorf("signal channel closed")
+}
+
+// isTerminal 判断文件描述符是否为终端
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
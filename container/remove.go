@@ -1,46 +1,117 @@
 package container
 
 import (
+	"errors"
 	"fmt"
 	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/filesys"
 	"github.com/phper95/tinydocker/pkg/logger"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 )
 
 // Remove removes a container
 func Remove(containerName string, force bool) error {
 	// 查找容器信息
-	targetInfo, err := GetContainerInfoByName(containerName)
+	targetInfo, unlock, err := lockContainer(containerName)
 	if err != nil {
 		logger.Error("failed to get container info: %v", err)
 		return err
 	}
+	defer unlock()
+	return remove(containerName, targetInfo, force)
+}
 
-	if targetInfo == nil {
-		return fmt.Errorf("container %s not found", containerName)
-	}
+// remove 删除容器，调用前必须持有容器锁
+func remove(containerName string, targetInfo *models.Info, force bool) error {
 
-	// 如果容器正在运行且没有使用force参数，则返回错误
-	if targetInfo.State == models.ContainerStateRunning && !force {
+	// 如果容器正在运行（包括暂停和等待重启）且没有使用force参数，则返回错误
+	running := targetInfo.State == models.ContainerStateRunning ||
+		targetInfo.State == models.ContainerStatePaused ||
+		targetInfo.State == models.ContainerStateRestarting
+	if running && !force {
 		return fmt.Errorf("cannot remove running container %s, use -f to force remove", containerName)
 	}
 
 	// 如果容器正在运行且使用了force参数，则先停止容器
-	if targetInfo.State == models.ContainerStateRunning && force {
-		err := Stop(containerName)
+	if running && force {
+		err := stop(containerName, targetInfo, 0)
 		if err != nil {
 			return fmt.Errorf("failed to stop container %s: %v", containerName, err)
 		}
+		// 停止时已经释放了网络等资源，重新读取配置；使用 --rm 的容器此时已被删除
+		targetInfo, err = models.GetContainerInfo(targetInfo.Id)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
 	}
 
-	// 删除容器目录
-	containerDir := filepath.Join(models.DefaultContainerInfoPath, targetInfo.Id)
-	err = os.RemoveAll(containerDir)
-	if err != nil {
-		return fmt.Errorf("failed to remove container directory %s: %v", containerDir, err)
+	// OCI bundle 容器 create 之后 init 进程阻塞在 exec.fifo 上，删除前结束它
+	if targetInfo.State == models.ContainerStateCreated && targetInfo.Pid > 0 && models.IsContainerProcessAlive(targetInfo) {
+		if err := syscall.Kill(targetInfo.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to kill container %s: %v", containerName, err)
+		}
+		// 等待监控进程记录退出，避免它在记录删除后重新写入
+		if _, err := waitContainerStopped(targetInfo.Id, DefaultStopTimeout); err != nil {
+			logger.Warn("container %s: %v", containerName, err)
+		}
 	}
 
+	if err := removeContainer(targetInfo); err != nil {
+		return err
+	}
 	logger.Info("Container %s removed", containerName)
 	return nil
 }
+
+// removeContainer 释放容器的全部资源并删除容器目录，rm 和 --rm 共用
+func removeContainer(info *models.Info) error {
+	// 卸载残留的挂载点，卸载失败时由 removeContainerDir 再次检查，仍有挂载点则放弃删除
+	if err := cleanup(info); err != nil {
+		logger.Warn("container %s: %v", info.Id, err)
+	}
+	return removeContainerDir(info.Id)
+}
+
+// removeContainerDir 删除容器目录（日志和 overlay 的 upper/work 层）和数据库中的容器记录。
+// 容器目录下还有挂载点（overlay 或 -v 的数据卷）时 RemoveAll 会穿透进去删除镜像层或宿主机上的数据，
+// 这种情况下保留目录和记录并返回错误
+func removeContainerDir(containerID string) error {
+	containerDir := filepath.Join(models.DefaultContainerInfoPath, containerID)
+	if err := ensureUnmounted(containerDir); err != nil {
+		return fmt.Errorf("failed to remove container %s: %v", containerID, err)
+	}
+	if err := os.RemoveAll(containerDir); err != nil {
+		return fmt.Errorf("failed to remove container directory %s: %v", containerDir, err)
+	}
+	if err := models.DeleteContainerInfo(containerID); err != nil {
+		return fmt.Errorf("failed to remove container %s: %v", containerID, err)
+	}
+	return nil
+}
+
+// ensureUnmounted 以 MNT_DETACH 卸载 dir 下残留的挂载点，卸载后仍有挂载点时返回错误
+func ensureUnmounted(dir string) error {
+	mounts, err := filesys.MountsUnder(dir)
+	if err != nil {
+		return err
+	}
+	// 从里层开始卸载，数据卷挂载在 overlay 的挂载点内部
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if err := syscall.Unmount(mounts[i], syscall.MNT_DETACH); err != nil && err != syscall.EINVAL {
+			logger.Warn("Failed to unmount %s: %v", mounts[i], err)
+		}
+	}
+	if mounts, err = filesys.MountsUnder(dir); err != nil {
+		return err
+	}
+	if len(mounts) > 0 {
+		return fmt.Errorf("%s still mounted", strings.Join(mounts, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/phper95/tinydocker/filesys"
+)
+
+// 容器目录下残留的挂载点（包括嵌套在里层的数据卷）被卸载后才能删除，宿主机上的文件不受影响
+func TestEnsureUnmounted(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mounting requires root")
+	}
+	containerDir := t.TempDir()
+	hostDir := t.TempDir()
+	hostFile := filepath.Join(hostDir, "data")
+	if err := os.WriteFile(hostFile, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := filepath.Join(containerDir, "overlay")
+	volume := filepath.Join(merged, "data dir")
+	if err := os.MkdirAll(merged, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mount("tmpfs", merged, "tmpfs", 0, ""); err != nil {
+		t.Skipf("mount tmpfs: %v", err)
+	}
+	defer syscall.Unmount(merged, syscall.MNT_DETACH)
+	if err := os.Mkdir(volume, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mount(hostDir, volume, "none", syscall.MS_BIND, ""); err != nil {
+		t.Fatalf("bind mount: %v", err)
+	}
+	defer syscall.Unmount(volume, syscall.MNT_DETACH)
+
+	mounts, err := filesys.MountsUnder(containerDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 2 || mounts[0] != merged || mounts[1] != volume {
+		t.Fatalf("MountsUnder = %q, want [%q %q]", mounts, merged, volume)
+	}
+
+	if err := ensureUnmounted(containerDir); err != nil {
+		t.Fatalf("ensureUnmounted error: %v", err)
+	}
+	if mounts, _ := filesys.MountsUnder(containerDir); len(mounts) != 0 {
+		t.Errorf("still mounted after ensureUnmounted: %q", mounts)
+	}
+	if err := os.RemoveAll(containerDir); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(hostFile); err != nil || string(data) != "keep" {
+		t.Errorf("host volume data = %q, %v after removing the container dir", data, err)
+	}
+}
@@ -0,0 +1,106 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+var (
+	// ErrContainerNotFound 没有与参数匹配的容器
+	ErrContainerNotFound = errors.New("no such container")
+	// ErrAmbiguousContainer 参数是多个容器ID的前缀
+	ErrAmbiguousContainer = errors.New("multiple containers match")
+	// ErrInvalidContainerState 容器当前的状态不允许执行该操作，例如暂停已经停止的容器
+	ErrInvalidContainerState = errors.New("invalid container state")
+)
+
+// stateError 说明具体原因的 ErrInvalidContainerState
+type stateError struct {
+	msg string
+}
+
+func (e *stateError) Error() string {
+	return e.msg
+}
+
+func (e *stateError) Is(target error) bool {
+	return target == ErrInvalidContainerState
+}
+
+// errInvalidState 返回满足 errors.Is(err, ErrInvalidContainerState) 的错误
+func errInvalidState(format string, args ...interface{}) error {
+	return &stateError{msg: fmt.Sprintf(format, args...)}
+}
+
+// ResolveContainer 根据完整ID、容器名称或唯一的ID前缀查找容器，所有按名称或ID操作容器的命令和接口都使用它。
+// 匹配顺序与 docker 一致：完整ID优先，其次是名称，最后是ID前缀，前缀匹配到多个容器时报错
+func ResolveContainer(ref string) (*models.Info, error) {
+	if ref == "" {
+		return nil, errors.New("container name or ID cannot be empty")
+	}
+	info, err := models.GetContainerInfo(ref)
+	if errors.Is(err, os.ErrNotExist) {
+		info, err = models.GetContainerInfoByName(ref)
+	}
+	if err == nil {
+		models.ReconcileContainerState(info)
+		return info, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	prefixMatches, err := models.FindContainersByIDPrefix(ref)
+	if err != nil {
+		return nil, err
+	}
+	switch len(prefixMatches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, ref)
+	case 1:
+		info = &prefixMatches[0]
+		models.ReconcileContainerState(info)
+		return info, nil
+	}
+	ids := make([]string, 0, len(prefixMatches))
+	for _, info := range prefixMatches {
+		ids = append(ids, models.TruncateID(info.Id))
+	}
+	return nil, fmt.Errorf("%w %q: %s", ErrAmbiguousContainer, ref, strings.Join(ids, ", "))
+}
+
+// GetContainerByID 只按完整ID查找容器，不匹配名称和ID前缀。OCI runtime 命令由上层工具传入确切的ID，
+// 不能因为前缀或名称匹配而操作到其他容器
+func GetContainerByID(containerID string) (*models.Info, error) {
+	if containerID == "" {
+		return nil, errors.New("container ID cannot be empty")
+	}
+	info, err := models.GetContainerInfo(containerID)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+		}
+		return nil, err
+	}
+	models.ReconcileContainerState(info)
+	return info, nil
+}
+
+// checkNameAvailable 校验容器名称合法且没有被其他容器使用
+func checkNameAvailable(name string) error {
+	if !containerIDPattern.MatchString(name) {
+		return fmt.Errorf("invalid container name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	info, err := models.GetContainerInfoByName(name)
+	if err == nil {
+		return fmt.Errorf("container name %q is already in use by container %s", name, models.TruncateID(info.Id))
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
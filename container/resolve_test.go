@@ -0,0 +1,82 @@
+package container
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+func TestResolveContainer(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(models.UseContainerRepository(filepath.Join(dir, "containers.db"), filepath.Join(dir, "containers")))
+
+	web := "abc1230000000000000000000000000a"
+	db := "abd4560000000000000000000000000b"
+	cache := "ffff000000000000000000000000000c"
+	for _, info := range []*models.Info{
+		{Id: web, Name: "web"},
+		// 名称同时是 web 的ID前缀
+		{Id: db, Name: "abc1"},
+		// 名称与 db 的完整ID相同
+		{Id: cache, Name: db},
+	} {
+		info.State = models.ContainerStateStopped
+		if err := models.WriteContainerInfo(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		ref     string
+		wantID  string
+		wantErr error
+	}{
+		{ref: web, wantID: web},
+		{ref: "web", wantID: web},
+		{ref: "abc12", wantID: web},
+		{ref: "ff", wantID: cache},
+		// 名称优先于ID前缀
+		{ref: "abc1", wantID: db},
+		// 完整ID优先于名称
+		{ref: db, wantID: db},
+		{ref: "ab", wantErr: ErrAmbiguousContainer},
+		{ref: "a", wantErr: ErrAmbiguousContainer},
+		{ref: "nope", wantErr: ErrContainerNotFound},
+		{ref: web + "0", wantErr: ErrContainerNotFound},
+	}
+	for _, tt := range tests {
+		info, err := ResolveContainer(tt.ref)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ResolveContainer(%q) error = %v, want %v", tt.ref, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveContainer(%q) error: %v", tt.ref, err)
+		} else if info.Id != tt.wantID {
+			t.Errorf("ResolveContainer(%q) = %s, want %s", tt.ref, info.Id, tt.wantID)
+		}
+	}
+
+	// 有歧义时列出所有匹配的容器
+	if _, err := ResolveContainer("ab"); err == nil || !strings.Contains(err.Error(), models.TruncateID(web)) || !strings.Contains(err.Error(), models.TruncateID(db)) {
+		t.Errorf("ambiguous error %v does not list the matching containers", err)
+	}
+	if _, err := ResolveContainer(""); err == nil || errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("ResolveContainer(\"\") error = %v, want an invalid argument error", err)
+	}
+
+	// runtime 命令只接受完整ID
+	if info, err := GetContainerByID(web); err != nil || info.Id != web {
+		t.Errorf("GetContainerByID(web ID) = %v, %v", info, err)
+	}
+	for _, ref := range []string{"web", "abc12", "ff"} {
+		if _, err := GetContainerByID(ref); !errors.Is(err, ErrContainerNotFound) {
+			t.Errorf("GetContainerByID(%q) error = %v, want %v", ref, err, ErrContainerNotFound)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package container
+
+import (
+	"fmt"
+	"github.com/phper95/tinydocker/container/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	restartBackoffMin    = 100 * time.Millisecond // 第一次自动重启前的等待时间
+	restartBackoffMax    = time.Minute            // 自动重启的最长等待时间
+	restartResetDuration = 10 * time.Second       // 容器运行超过该时间后退出，退避时间重新计算
+)
+
+// ParseRestartPolicy 解析 --restart 参数，格式为 no|on-failure[:N]|always|unless-stopped
+func ParseRestartPolicy(policy string) (models.RestartPolicy, error) {
+	if policy == "" {
+		return models.RestartPolicy{Name: models.RestartPolicyNo}, nil
+	}
+	name, count, hasCount := strings.Cut(policy, ":")
+	switch name {
+	case models.RestartPolicyNo, models.RestartPolicyAlways, models.RestartPolicyUnlessStopped:
+		if hasCount {
+			return models.RestartPolicy{}, fmt.Errorf("maximum retry count cannot be used with restart policy %s", name)
+		}
+		return models.RestartPolicy{Name: name}, nil
+	case models.RestartPolicyOnFailure:
+		rp := models.RestartPolicy{Name: name}
+		if hasCount {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return models.RestartPolicy{}, fmt.Errorf("invalid maximum retry count: %s", count)
+			}
+			rp.MaximumRetryCount = n
+		}
+		return rp, nil
+	default:
+		return models.RestartPolicy{}, fmt.Errorf("invalid restart policy %s", policy)
+	}
+}
+
+// shouldRestart 根据重启策略和退出码判断容器是否需要自动重启。
+// 没有守护进程，always 和 unless-stopped 的行为一致：除非用户执行了 stop，否则总是重启
+func shouldRestart(info *models.Info, exitCode int) bool {
+	if info.ManuallyStopped {
+		return false
+	}
+	switch info.RestartPolicy.Name {
+	case models.RestartPolicyAlways, models.RestartPolicyUnlessStopped:
+		return true
+	case models.RestartPolicyOnFailure:
+		if exitCode == 0 {
+			return false
+		}
+		max := info.RestartPolicy.MaximumRetryCount
+		return max == 0 || info.RestartCount < max
+	default:
+		return false
+	}
+}
+
+// restartBackoff 返回本次重启前的等待时间，容器稳定运行超过 restartResetDuration 后才退出时重新从最小退避时间开始
+func restartBackoff(backoff, ranFor time.Duration) time.Duration {
+	if ranFor > restartResetDuration {
+		return restartBackoffMin
+	}
+	return backoff
+}
+
+// nextRestartBackoff 每次重启后等待时间翻倍，最长不超过 restartBackoffMax
+func nextRestartBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > restartBackoffMax {
+		return restartBackoffMax
+	}
+	return backoff
+}
@@ -0,0 +1,132 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	valid := map[string]models.RestartPolicy{
+		"":               {Name: models.RestartPolicyNo},
+		"no":             {Name: models.RestartPolicyNo},
+		"always":         {Name: models.RestartPolicyAlways},
+		"unless-stopped": {Name: models.RestartPolicyUnlessStopped},
+		"on-failure":     {Name: models.RestartPolicyOnFailure},
+		"on-failure:5":   {Name: models.RestartPolicyOnFailure, MaximumRetryCount: 5},
+		// 0 与不指定次数相同，表示不限制重试次数
+		"on-failure:0": {Name: models.RestartPolicyOnFailure},
+	}
+	for policy, want := range valid {
+		got, err := ParseRestartPolicy(policy)
+		if err != nil {
+			t.Errorf("ParseRestartPolicy(%q) error: %v", policy, err)
+		} else if got != want {
+			t.Errorf("ParseRestartPolicy(%q) = %+v, want %+v", policy, got, want)
+		}
+	}
+
+	for _, policy := range []string{"on-failure:-1", "on-failure:x", "on-failure:", "always:3", "unless-stopped:1", "no:0", "never"} {
+		if got, err := ParseRestartPolicy(policy); err == nil {
+			t.Errorf("ParseRestartPolicy(%q) = %+v, want error", policy, got)
+		}
+	}
+}
+
+// runRestartLoop 按 monitor 的方式处理一串退出码：每次退出时判断是否重启，重启前等待退避时间，
+// 返回实际重启的次数和每次重启前的等待时间。ranFor 为每次退出前容器运行的时间，为空时视为立即退出
+func runRestartLoop(info *models.Info, exitCodes []int, ranFor ...time.Duration) (int, []time.Duration) {
+	var waits []time.Duration
+	backoff := restartBackoffMin
+	for i, code := range exitCodes {
+		if !shouldRestart(info, code) {
+			break
+		}
+		if i < len(ranFor) {
+			backoff = restartBackoff(backoff, ranFor[i])
+		}
+		waits = append(waits, backoff)
+		backoff = nextRestartBackoff(backoff)
+		info.RestartCount++
+	}
+	return info.RestartCount, waits
+}
+
+func TestRestartLoop(t *testing.T) {
+	failing := []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+
+	t.Run("on-failure stops after max retries", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyOnFailure, MaximumRetryCount: 3}}
+		if n, _ := runRestartLoop(info, failing); n != 3 {
+			t.Errorf("restarted %d times, want 3", n)
+		}
+	})
+
+	t.Run("on-failure:0 keeps retrying", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyOnFailure}}
+		if n, _ := runRestartLoop(info, failing); n != len(failing) {
+			t.Errorf("restarted %d times, want %d", n, len(failing))
+		}
+	})
+
+	t.Run("on-failure stops on success", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyOnFailure, MaximumRetryCount: 5}}
+		if n, _ := runRestartLoop(info, []int{2, 137, 0, 1}); n != 2 {
+			t.Errorf("restarted %d times, want 2", n)
+		}
+	})
+
+	t.Run("always restarts on success", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyAlways}}
+		if n, _ := runRestartLoop(info, []int{0, 0, 0}); n != 3 {
+			t.Errorf("restarted %d times, want 3", n)
+		}
+	})
+
+	t.Run("manual stop wins over policy", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyUnlessStopped}, ManuallyStopped: true}
+		if n, _ := runRestartLoop(info, failing); n != 0 {
+			t.Errorf("restarted %d times, want 0", n)
+		}
+	})
+
+	t.Run("no never restarts", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyNo}}
+		if n, _ := runRestartLoop(info, failing); n != 0 {
+			t.Errorf("restarted %d times, want 0", n)
+		}
+	})
+
+	t.Run("backoff doubles up to the maximum", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyAlways}}
+		_, waits := runRestartLoop(info, make([]int, 12))
+		if waits[0] != restartBackoffMin {
+			t.Errorf("first wait = %v, want %v", waits[0], restartBackoffMin)
+		}
+		for i := 1; i < len(waits); i++ {
+			want := waits[i-1] * 2
+			if want > restartBackoffMax {
+				want = restartBackoffMax
+			}
+			if waits[i] != want {
+				t.Errorf("wait %d = %v, want %v", i, waits[i], want)
+			}
+		}
+		if last := waits[len(waits)-1]; last != restartBackoffMax {
+			t.Errorf("last wait = %v, want the maximum %v", last, restartBackoffMax)
+		}
+	})
+
+	t.Run("backoff resets after a stable run", func(t *testing.T) {
+		info := &models.Info{RestartPolicy: models.RestartPolicy{Name: models.RestartPolicyAlways}}
+		ranFor := []time.Duration{0, time.Second, 0, restartResetDuration + time.Second, 0, restartResetDuration}
+		_, waits := runRestartLoop(info, make([]int, len(ranFor)), ranFor...)
+		min := restartBackoffMin
+		want := []time.Duration{min, 2 * min, 4 * min, min, 2 * min, 4 * min}
+		if !reflect.DeepEqual(waits, want) {
+			t.Errorf("waits = %v, want %v", waits, want)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	// DefaultShimLogFileName 监控进程自身的日志文件
+	DefaultShimLogFileName = "shim.log"
+	// 监控进程通过该文件描述符通知 CLI 容器是否启动成功
+	shimReadyFd = 3
+)
+
+// spawnShim 启动一个脱离 CLI 的监控进程（类似 containerd-shim / conmon）托管后台容器，
+// 并阻塞到容器启动完成。监控进程负责持有init进程、记录退出状态和清理资源，CLI 退出后依然存在
+func spawnShim(info *models.Info) error {
+	// 先写入配置，监控进程会从容器记录读取运行参数
+	if err := models.WriteContainerInfo(info); err != nil {
+		logger.Error("Failed to write container info error: ", err)
+		return err
+	}
+
+	read, write, err := os.Pipe()
+	if err != nil {
+		logger.Error("Failed to create pipe error: ", err)
+		return err
+	}
+	defer read.Close()
+
+	shimLog, err := os.OpenFile(filepath.Join(models.DefaultContainerInfoPath, info.Id, DefaultShimLogFileName),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Error("Failed to create shim log file: ", err)
+		write.Close()
+		return err
+	}
+	defer shimLog.Close()
+
+	shimCmd := exec.Command("/proc/self/exe", "shim", info.Id)
+	// Setsid 让监控进程脱离 CLI 所在的会话，终端关闭或 CLI 退出时不会收到 SIGHUP
+	shimCmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	shimCmd.Stdout = shimLog
+	shimCmd.Stderr = shimLog
+	shimCmd.ExtraFiles = []*os.File{write}
+	if err := shimCmd.Start(); err != nil {
+		write.Close()
+		logger.Error("Failed to start shim process error: ", err)
+		return err
+	}
+	// 关闭父进程持有的写端，监控进程关闭写端后 ReadAll 才会返回
+	write.Close()
+
+	msg, err := io.ReadAll(read)
+	if err != nil {
+		return fmt.Errorf("read shim ready pipe error: %v", err)
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("start container %s failed: %s", info.Id, string(msg))
+	}
+	logger.Info("Container running in background, shim pid: %d", shimCmd.Process.Pid)
+	// 不等待监控进程，由系统 init 进程回收
+	return shimCmd.Process.Release()
+}
+
+// RunShim 是监控进程的入口：启动容器init进程，通知 CLI 启动结果，然后一直等到容器退出
+func RunShim(containerID string) error {
+	ready := os.NewFile(uintptr(shimReadyFd), "ready")
+	if ready == nil {
+		return errors.New("shim ready pipe not found")
+	}
+
+	info, err := models.GetContainerInfo(containerID)
+	if err != nil {
+		ready.WriteString(err.Error())
+		ready.Close()
+		return err
+	}
+
+	initCmd, err := launchContainer(info, nil)
+	if err != nil {
+		ready.WriteString(err.Error())
+		ready.Close()
+		launchFailed(info, err)
+		return err
+	}
+	ready.Close()
+
+	logger.Info("shim monitoring container %s, pid %d", info.Id, info.Pid)
+	return monitor(info, initCmd, nil)
+}
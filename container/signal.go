@@ -0,0 +1,152 @@
+package container
+
+import (
+	"fmt"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/pkg/logger"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames 常用信号与名称的对应关系
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT:   "SIGABRT",
+	syscall.SIGALRM:   "SIGALRM",
+	syscall.SIGBUS:    "SIGBUS",
+	syscall.SIGCHLD:   "SIGCHLD",
+	syscall.SIGCONT:   "SIGCONT",
+	syscall.SIGFPE:    "SIGFPE",
+	syscall.SIGHUP:    "SIGHUP",
+	syscall.SIGILL:    "SIGILL",
+	syscall.SIGINT:    "SIGINT",
+	syscall.SIGIO:     "SIGIO",
+	syscall.SIGKILL:   "SIGKILL",
+	syscall.SIGPIPE:   "SIGPIPE",
+	syscall.SIGPROF:   "SIGPROF",
+	syscall.SIGPWR:    "SIGPWR",
+	syscall.SIGQUIT:   "SIGQUIT",
+	syscall.SIGSEGV:   "SIGSEGV",
+	syscall.SIGSTOP:   "SIGSTOP",
+	syscall.SIGSYS:    "SIGSYS",
+	syscall.SIGTERM:   "SIGTERM",
+	syscall.SIGTRAP:   "SIGTRAP",
+	syscall.SIGTSTP:   "SIGTSTP",
+	syscall.SIGTTIN:   "SIGTTIN",
+	syscall.SIGTTOU:   "SIGTTOU",
+	syscall.SIGURG:    "SIGURG",
+	syscall.SIGUSR1:   "SIGUSR1",
+	syscall.SIGUSR2:   "SIGUSR2",
+	syscall.SIGVTALRM: "SIGVTALRM",
+	syscall.SIGWINCH:  "SIGWINCH",
+	syscall.SIGXCPU:   "SIGXCPU",
+	syscall.SIGXFSZ:   "SIGXFSZ",
+}
+
+// 实时信号的范围，与 glibc 和 docker 一致：内核的 32、33 号信号被 NPTL 占用，SIGRTMIN 为 34
+const (
+	sigRTMin = 34
+	sigRTMax = 64
+)
+
+// SignalName 返回信号的名称，例如 SIGTERM，未知信号返回其数值
+func SignalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	if sig == sigRTMin {
+		return "SIGRTMIN"
+	}
+	if sig > sigRTMin && sig <= sigRTMax {
+		return "SIGRTMIN+" + strconv.Itoa(int(sig)-sigRTMin)
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// ParseSignal 解析信号参数，支持 SIGKILL、KILL、kill、数值形式 9，
+// 以及实时信号 SIGRTMIN、SIGRTMIN+N、SIGRTMAX、SIGRTMAX-N
+func ParseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n <= 0 || n > sigRTMax {
+			return 0, fmt.Errorf("invalid signal: %s", s)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	for sig, n := range signalNames {
+		if n == name {
+			return sig, nil
+		}
+	}
+	if sig, ok := parseRealtimeSignal(name); ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("invalid signal: %s", s)
+}
+
+// parseRealtimeSignal 解析 SIGRTMIN+N 和 SIGRTMAX-N 形式的实时信号
+func parseRealtimeSignal(name string) (syscall.Signal, bool) {
+	var base, sign int
+	var offset string
+	switch {
+	case strings.HasPrefix(name, "SIGRTMIN"):
+		base, sign, offset = sigRTMin, 1, strings.TrimPrefix(name, "SIGRTMIN")
+	case strings.HasPrefix(name, "SIGRTMAX"):
+		base, sign, offset = sigRTMax, -1, strings.TrimPrefix(name, "SIGRTMAX")
+	default:
+		return 0, false
+	}
+	var n uint64
+	if offset != "" {
+		op := "+"
+		if sign < 0 {
+			op = "-"
+		}
+		if !strings.HasPrefix(offset, op) {
+			return 0, false
+		}
+		var err error
+		if n, err = strconv.ParseUint(offset[1:], 10, 8); err != nil {
+			return 0, false
+		}
+	}
+	sig := base + sign*int(n)
+	if sig < sigRTMin || sig > sigRTMax {
+		return 0, false
+	}
+	return syscall.Signal(sig), true
+}
+
+// forwardSignals 将前台 CLI 收到的 SIGINT/SIGTERM 转发给容器的init进程，CLI 继续等待容器退出。
+// 每次转发时重新读取容器记录，容器自动重启后 PID 会变化。返回的函数用于停止转发
+func forwardSignals(containerID string) func() {
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				info, err := models.GetContainerInfo(containerID)
+				if err != nil || info.Pid <= 0 {
+					continue
+				}
+				s := sig.(syscall.Signal)
+				if err := syscall.Kill(info.Pid, s); err != nil && err != syscall.ESRCH {
+					logger.Error("Failed to forward signal %s to container %s: %v", SignalName(s), containerID, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,80 @@
+package container
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestParseSignalNames(t *testing.T) {
+	// 每个已知信号的名称都可以解析回同一个信号，不区分大小写，可以省略 SIG 前缀
+	for sig, name := range signalNames {
+		for _, s := range []string{name, name[3:], "sig" + name[3:], SignalName(sig)} {
+			got, err := ParseSignal(s)
+			if err != nil || got != sig {
+				t.Errorf("ParseSignal(%q) = %d, %v, want %d", s, got, err, sig)
+			}
+		}
+	}
+}
+
+func TestParseSignalNumbers(t *testing.T) {
+	for n := 1; n <= 64; n++ {
+		s := SignalName(syscall.Signal(n))
+		got, err := ParseSignal(s)
+		if err != nil || got != syscall.Signal(n) {
+			t.Errorf("ParseSignal(%q) = %d, %v, want %d", s, got, err, n)
+		}
+	}
+	for _, s := range []string{"0", "-9", "65", "", "SIG", "SIGFOO", "KILL9", "9x"} {
+		if got, err := ParseSignal(s); err == nil {
+			t.Errorf("ParseSignal(%q) = %d, want error", s, got)
+		}
+	}
+}
+
+// 解析出的信号发给进程后，监控进程记录的退出码和信号名与 docker 一致
+func TestParsedSignalExitResult(t *testing.T) {
+	for _, s := range []string{"TERM", "kill", "SIGUSR1", "2"} {
+		sig, err := ParseSignal(s)
+		if err != nil {
+			t.Fatalf("ParseSignal(%q) error: %v", s, err)
+		}
+		cmd := exec.Command("sleep", "30")
+		if err := cmd.Start(); err != nil {
+			t.Skipf("start sleep: %v", err)
+		}
+		if err := syscall.Kill(cmd.Process.Pid, sig); err != nil {
+			t.Fatalf("kill %d: %v", cmd.Process.Pid, err)
+		}
+		cmd.Wait()
+		result := newExitResult(cmd.ProcessState)
+		if result.Code != 128+int(sig) || result.Signal != SignalName(sig) {
+			t.Errorf("signal %q: exit result = %+v, want code %d signal %s", s, result, 128+int(sig), SignalName(sig))
+		}
+	}
+}
+
+func TestParseRealtimeSignal(t *testing.T) {
+	valid := map[string]syscall.Signal{
+		"SIGRTMIN":   34,
+		"SIGRTMIN+3": 37,
+		"rtmin+3":    37,
+		"RTMIN+30":   64,
+		"SIGRTMAX":   64,
+		"SIGRTMAX-2": 62,
+	}
+	for s, want := range valid {
+		if got, err := ParseSignal(s); err != nil || got != want {
+			t.Errorf("ParseSignal(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"SIGRTMIN+31", "SIGRTMIN-1", "SIGRTMAX+1", "SIGRTMAX-31", "SIGRTMIN+", "SIGRTMIN++3", "SIGRTMINX"} {
+		if got, err := ParseSignal(s); err == nil {
+			t.Errorf("ParseSignal(%q) = %d, want error", s, got)
+		}
+	}
+	if name := SignalName(37); name != "SIGRTMIN+3" {
+		t.Errorf("SignalName(37) = %q, want SIGRTMIN+3", name)
+	}
+}
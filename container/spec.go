@@ -0,0 +1,179 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phper95/tinydocker/container/models"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// InitSpecVersion 父进程与 init 进程之间传递的启动规格版本，格式不兼容时需要升级
+const InitSpecVersion = "1"
+
+// InitSpec 父进程通过管道传给容器 init 进程的启动规格（JSON 格式），
+// 包含 init 进程在 exec 用户命令之前需要完成的全部配置
+type InitSpec struct {
+	Version  string      `json:"version"`  // 规格版本
+	Args     []string    `json:"args"`     // 用户命令及参数，不再按空格拆分
+	Env      []string    `json:"env"`      // 用户进程的环境变量
+	Cwd      string      `json:"cwd"`      // 用户进程的工作目录
+	User     string      `json:"user"`     // 运行用户 <name|uid>[:<group|gid>]，init 进程根据容器内的 /etc/passwd 解析，为空时使用 root
+	Hostname string      `json:"hostname"` // 容器主机名
+	Domain   string      `json:"domain"`   // 容器 NIS 域名
+	Rlimits  []Rlimit    `json:"rlimits"`  // 资源限制
+	Mounts   []MountSpec `json:"mounts"`   // 需要挂载的文件系统，按顺序执行
+
+	AdditionalGids []uint32 `json:"additional_gids"` // 运行用户的附加组
+	ReadonlyRootfs bool     `json:"readonly_rootfs"` // 挂载完成后将根文件系统设置为只读
+	DefaultDevices bool     `json:"default_devices"` // 是否在 /dev 中创建 OCI 规定的默认设备
+	Init           bool     `json:"init"`            // 是否保留 init 进程作为 PID 1，回收孤儿进程并转发信号
+	ExecFifo       string   `json:"exec_fifo"`       // 不为空时 init 进程阻塞在该 FIFO 上，直到 runtime start 打开它
+}
+
+// Rlimit 资源限制，Type 为 RLIMIT_NOFILE 等名称
+type Rlimit struct {
+	Type string `json:"type"`
+	Soft uint64 `json:"soft"`
+	Hard uint64 `json:"hard"`
+}
+
+// MountSpec 容器内的挂载指令，参数与 syscall.Mount 一致。
+// 绑定挂载（MS_BIND）的源路径位于宿主机，在 pivot_root 之前挂载，其余在 pivot_root 之后挂载
+type MountSpec struct {
+	Source string  `json:"source"`
+	Target string  `json:"target"`
+	Type   string  `json:"type"`
+	Flags  uintptr `json:"flags"`
+	Data   string  `json:"data"`
+}
+
+// rlimitTypes 支持的资源限制类型
+var rlimitTypes = map[string]int{
+	"RLIMIT_AS":     syscall.RLIMIT_AS,
+	"RLIMIT_CORE":   syscall.RLIMIT_CORE,
+	"RLIMIT_CPU":    syscall.RLIMIT_CPU,
+	"RLIMIT_DATA":   syscall.RLIMIT_DATA,
+	"RLIMIT_FSIZE":  syscall.RLIMIT_FSIZE,
+	"RLIMIT_NOFILE": syscall.RLIMIT_NOFILE,
+	"RLIMIT_STACK":  syscall.RLIMIT_STACK,
+	"RLIMIT_NPROC":  6, // syscall 包中没有定义，取 Linux 的值
+}
+
+// defaultMounts 容器默认的挂载：独立的 /proc 和 /dev，参数说明见 filesys.MountProc 和 filesys.MountTmpfs
+var defaultMounts = []MountSpec{
+	{Source: "proc", Target: "/proc", Type: "proc", Flags: syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOSUID},
+	{Source: "none", Target: "/dev", Type: "devtmpfs", Flags: syscall.MS_RELATIME, Data: "mode=755"},
+}
+
+// NewInitSpec 根据容器配置生成 init 进程的启动规格
+func NewInitSpec(info *models.Info) (*InitSpec, error) {
+	if info.Bundle != "" {
+		return newBundleInitSpec(info)
+	}
+	rlimits, err := ParseUlimits(info.Ulimits)
+	if err != nil {
+		return nil, err
+	}
+	cwd := info.WorkingDir
+	if cwd == "" {
+		cwd = "/"
+	}
+	hostname := info.Hostname
+	if hostname == "" && len(info.Id) >= 12 {
+		hostname = info.Id[:12]
+	}
+	// 旧版本创建的容器只记录了用户指定的变量，补充默认的 PATH
+	env := info.Env
+	if !hasEnv(env, "PATH") {
+		env = append([]string{DefaultPathEnv}, env...)
+	}
+	return &InitSpec{
+		Version:  InitSpecVersion,
+		Args:     info.Args,
+		Env:      env,
+		Cwd:      cwd,
+		User:     info.User,
+		Hostname: hostname,
+		Domain:   info.Domainname,
+		Init:     info.Init,
+		Rlimits:  rlimits,
+		Mounts:   defaultMounts,
+	}, nil
+}
+
+// Validate 校验启动规格，init 进程在执行任何操作前调用
+func (s *InitSpec) Validate() error {
+	if s.Version != InitSpecVersion {
+		return fmt.Errorf("unsupported init spec version %q, expected %q", s.Version, InitSpecVersion)
+	}
+	if len(s.Args) == 0 || s.Args[0] == "" {
+		return errors.New("init spec args cannot be empty")
+	}
+	if s.Cwd != "" && !filepath.IsAbs(s.Cwd) {
+		return fmt.Errorf("init spec cwd %q must be an absolute path", s.Cwd)
+	}
+	for _, env := range s.Env {
+		if !strings.Contains(env, "=") {
+			return fmt.Errorf("invalid env %q, expected KEY=VALUE", env)
+		}
+	}
+	for _, rl := range s.Rlimits {
+		if _, ok := rlimitTypes[rl.Type]; !ok {
+			return fmt.Errorf("unsupported rlimit type %s", rl.Type)
+		}
+		if rl.Soft > rl.Hard {
+			return fmt.Errorf("rlimit %s soft limit %d is greater than hard limit %d", rl.Type, rl.Soft, rl.Hard)
+		}
+	}
+	for _, m := range s.Mounts {
+		if !filepath.IsAbs(m.Target) {
+			return fmt.Errorf("mount target %q must be an absolute path", m.Target)
+		}
+	}
+	return nil
+}
+
+// ParseUlimits 解析 --ulimit 参数，格式为 name=soft[:hard]，例如 nofile=1024:2048，-1 表示不限制
+func ParseUlimits(ulimits []string) ([]Rlimit, error) {
+	var rlimits []Rlimit
+	for _, u := range ulimits {
+		name, limits, ok := strings.Cut(u, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ulimit %q, expected name=soft[:hard]", u)
+		}
+		rlType := "RLIMIT_" + strings.ToUpper(name)
+		if _, ok := rlimitTypes[rlType]; !ok {
+			return nil, fmt.Errorf("unsupported ulimit %s", name)
+		}
+		softStr, hardStr, hasHard := strings.Cut(limits, ":")
+		soft, err := parseRlimitValue(softStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ulimit %q: %v", u, err)
+		}
+		hard := soft
+		if hasHard {
+			if hard, err = parseRlimitValue(hardStr); err != nil {
+				return nil, fmt.Errorf("invalid ulimit %q: %v", u, err)
+			}
+		}
+		if soft > hard {
+			return nil, fmt.Errorf("invalid ulimit %q: soft limit is greater than hard limit", u)
+		}
+		rlimits = append(rlimits, Rlimit{Type: rlType, Soft: soft, Hard: hard})
+	}
+	return rlimits, nil
+}
+
+// rlimInfinity 即内核的 RLIM_INFINITY，表示不限制
+const rlimInfinity = ^uint64(0)
+
+// parseRlimitValue 解析资源限制的值，与 docker 一致，-1 表示不限制
+func parseRlimitValue(s string) (uint64, error) {
+	if s == "-1" {
+		return rlimInfinity, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
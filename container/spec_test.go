@@ -0,0 +1,106 @@
+package container
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+func TestParseUlimits(t *testing.T) {
+	got, err := ParseUlimits([]string{"nofile=1024:2048", "NPROC=64", "core=0", "as=-1", "stack=8192:-1"})
+	if err != nil {
+		t.Fatalf("ParseUlimits error: %v", err)
+	}
+	want := []Rlimit{
+		{Type: "RLIMIT_NOFILE", Soft: 1024, Hard: 2048},
+		{Type: "RLIMIT_NPROC", Soft: 64, Hard: 64},
+		{Type: "RLIMIT_CORE", Soft: 0, Hard: 0},
+		{Type: "RLIMIT_AS", Soft: rlimInfinity, Hard: rlimInfinity},
+		{Type: "RLIMIT_STACK", Soft: 8192, Hard: rlimInfinity},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseUlimits = %+v, want %+v", got, want)
+	}
+
+	invalid := []string{
+		"nofile",           // 缺少值
+		"nofile=",          // 值为空
+		"nofile=1024:",     // hard 为空
+		"nofile=abc",       // 不是数字
+		"nofile=2048:1024", // soft 大于 hard
+		"rtprio=1",         // 不支持的类型
+		"nofile=-1:1024",   // soft 为 unlimited 时大于 hard
+		"nofile=-2",        // 只有 -1 表示 unlimited
+	}
+	for _, u := range invalid {
+		if rl, err := ParseUlimits([]string{u}); err == nil {
+			t.Errorf("ParseUlimits(%q) = %+v, want error", u, rl)
+		}
+	}
+}
+
+// 启动规格经过管道发给 init 进程后内容不变，并且可以通过 init 进程的校验
+func TestInitSpecRoundTrip(t *testing.T) {
+	info := &models.Info{
+		Id:      "0123456789abcdef0123456789abcdef",
+		Args:    []string{"sh", "-c", "echo hello world"},
+		Ulimits: []string{"nofile=1024:2048"},
+	}
+	spec, err := NewInitSpec(info)
+	if err != nil {
+		t.Fatalf("NewInitSpec error: %v", err)
+	}
+	if !reflect.DeepEqual(spec.Args, info.Args) {
+		t.Errorf("spec args = %q, want %q", spec.Args, info.Args)
+	}
+	if want := []Rlimit{{Type: "RLIMIT_NOFILE", Soft: 1024, Hard: 2048}}; !reflect.DeepEqual(spec.Rlimits, want) {
+		t.Errorf("spec rlimits = %+v, want %+v", spec.Rlimits, want)
+	}
+
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer read.Close()
+	if err := SendInitSpec(spec, write); err != nil {
+		t.Fatalf("SendInitSpec error: %v", err)
+	}
+	var received InitSpec
+	if err := json.NewDecoder(read).Decode(&received); err != nil {
+		t.Fatalf("decode init spec error: %v", err)
+	}
+	if !reflect.DeepEqual(&received, spec) {
+		t.Errorf("received spec = %+v, want %+v", received, *spec)
+	}
+	if err := received.Validate(); err != nil {
+		t.Errorf("Validate error: %v", err)
+	}
+}
+
+func TestInitSpecValidate(t *testing.T) {
+	valid := func() *InitSpec {
+		return &InitSpec{Version: InitSpecVersion, Args: []string{"sh"}, Env: []string{"A=1"}, Cwd: "/", Mounts: defaultMounts}
+	}
+	invalid := map[string]func(s *InitSpec){
+		"version":      func(s *InitSpec) { s.Version = "0" },
+		"empty args":   func(s *InitSpec) { s.Args = nil },
+		"relative cwd": func(s *InitSpec) { s.Cwd = "tmp" },
+		"env":          func(s *InitSpec) { s.Env = []string{"A"} },
+		"rlimit type":  func(s *InitSpec) { s.Rlimits = []Rlimit{{Type: "RLIMIT_FOO"}} },
+		"rlimit order": func(s *InitSpec) { s.Rlimits = []Rlimit{{Type: "RLIMIT_NOFILE", Soft: 2, Hard: 1}} },
+		"mount target": func(s *InitSpec) { s.Mounts = []MountSpec{{Target: "proc"}} },
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+	for name, modify := range invalid {
+		s := valid()
+		modify(s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: Validate succeeded, want error", name)
+		}
+	}
+}
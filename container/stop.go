@@ -2,53 +2,135 @@ package container
 
 import (
 	"fmt"
+	"github.com/phper95/tinydocker/cgroups"
 	"github.com/phper95/tinydocker/container/models"
 	"github.com/phper95/tinydocker/pkg/logger"
-	"os"
-	"path/filepath"
 	"syscall"
+	"time"
 )
 
-// Stop stops a running container
-func Stop(containerName string) error {
+// monitorRecordTimeout 进程退出后等待监控进程写入退出状态的时间
+const monitorRecordTimeout = 2 * time.Second
+
+// Stop stops a running container. 先发送容器的停止信号（默认 SIGTERM），
+// timeout 内进程没有退出则发送 SIGKILL 强制结束
+func Stop(containerName string, timeout time.Duration) error {
 	// 查找容器信息
-	info, err := findContainerInfo(containerName)
+	info, unlock, err := lockContainer(containerName)
 	if err != nil {
 		return fmt.Errorf("failed to find container %s: %v", containerName, err)
 	}
+	defer unlock()
+	return stop(containerName, info, timeout)
+}
+
+// stop 停止容器，调用前必须持有容器锁
+func stop(containerName string, info *models.Info, timeout time.Duration) error {
+	// 容器处于重启等待中，标记为手动停止，监控进程不会再拉起容器
+	if info.State == models.ContainerStateRestarting {
+		if err := markManuallyStopped(info); err != nil {
+			return fmt.Errorf("failed to update container %s state: %v", containerName, err)
+		}
+		if _, err := waitContainerStopped(info.Id, DefaultStopTimeout); err != nil {
+			return err
+		}
+		logger.Info("Container %s stopped", containerName)
+		return nil
+	}
 
 	// 检查容器是否正在运行
-	if info.State != models.ContainerStateRunning {
+	paused := info.State == models.ContainerStatePaused
+	if info.State != models.ContainerStateRunning && !paused {
 		return fmt.Errorf("container %s is not running", containerName)
 	}
 
+	// 先标记为手动停止，监控进程记录退出状态时不会按重启策略拉起容器
+	if err := markManuallyStopped(info); err != nil {
+		return fmt.Errorf("failed to update container %s state: %v", containerName, err)
+	}
+
+	var err error
+	stopSignal := syscall.SIGTERM
+	if info.StopSignal != "" {
+		if stopSignal, err = ParseSignal(info.StopSignal); err != nil {
+			return err
+		}
+	}
+
 	// 向容器进程发送终止信号
 	pid := info.Pid
-	err = syscall.Kill(pid, syscall.SIGTERM)
-	if err != nil {
+	err = syscall.Kill(pid, stopSignal)
+	if err != nil && err != syscall.ESRCH {
 		logger.Error("Failed to send signal to container %s: %v", containerName, err)
 		return fmt.Errorf("failed to send signal to container %s: %v", containerName, err)
 	}
+	// 冻结的进程无法处理信号，发送信号后解冻，进程才能退出
+	if paused {
+		if err := cgroups.SetFrozen(cgroups.ContainerCgroupName(info.Id), false); err != nil {
+			return fmt.Errorf("failed to unpause container %s: %v", containerName, err)
+		}
+	}
 
-	// 更新容器状态为已停止
-	err = models.UpdateContainerState(info.Id, models.ContainerStateStopped)
+	// 容器状态由监控进程在进程真正退出后更新，这里只等待进程退出
+	if !waitProcessExit(pid, timeout) {
+		logger.Warn("container %s did not exit within %v, sending SIGKILL", containerName, timeout)
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to kill container %s: %v", containerName, err)
+		}
+		if !waitProcessExit(pid, DefaultStopTimeout) {
+			return fmt.Errorf("container %s did not exit after SIGKILL", containerName)
+		}
+	}
+	latest, err := waitContainerStopped(info.Id, monitorRecordTimeout)
+	if err != nil && latest != nil && models.IsMonitorProcessAlive(latest) {
+		// 监控进程清理完资源才写入 stopped，继续等待，避免与监控进程同时清理
+		latest, err = waitContainerStopped(info.Id, DefaultStopTimeout)
+		if err != nil {
+			return err
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update container %s state: %v", containerName, err)
+		// 没有监控进程（例如托管前台容器的 CLI 被杀死），由当前进程清理资源并记录退出
+		logger.Warn("no monitor recorded exit of container %s, recording it", containerName)
+		finishContainer(info, exitResult{Code: -1, Error: "container monitor not found, exit status unknown"})
 	}
 
 	logger.Info("Container %s stopped", containerName)
 	return nil
 }
 
-// findContainerInfo finds container info by name or ID
-func findContainerInfo(nameOrID string) (*models.Info, error) {
-	// 首先尝试按ID精确匹配
-	filePath := filepath.Join(models.DefaultContainerInfoPath, nameOrID, models.DefaultContainerInfoFileName)
-	if _, err := os.Stat(filePath); err == nil {
-		// 找到精确匹配的ID
-		return models.ReadContainerInfo(filePath)
+// Kill 向容器的init进程（容器内 PID 1）发送信号，不修改容器状态，
+// 容器因此退出时由监控进程记录退出状态
+func Kill(containerName string, sig syscall.Signal) error {
+	info, unlock, err := lockContainer(containerName)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerName, err)
 	}
+	defer unlock()
+	return kill(containerName, info, sig)
+}
+
+// kill 向容器的init进程发送信号，调用前必须持有容器锁
+func kill(containerName string, info *models.Info, sig syscall.Signal) error {
+	// OCI bundle 容器 create 之后 init 进程已经存在，也可以接收信号
+	created := info.State == models.ContainerStateCreated && info.Pid > 0
+	if info.State != models.ContainerStateRunning && info.State != models.ContainerStatePaused && !created {
+		return fmt.Errorf("container %s is not running", containerName)
+	}
+	if err := syscall.Kill(info.Pid, sig); err != nil {
+		logger.Error("Failed to send signal %s to container %s: %v", SignalName(sig), containerName, err)
+		return fmt.Errorf("failed to send signal %s to container %s: %v", SignalName(sig), containerName, err)
+	}
+	logger.Info("Sent signal %s to container %s", SignalName(sig), containerName)
+	return nil
+}
 
-	// 如果没有精确匹配，遍历所有容器查找匹配名称的容器
-	return GetContainerInfoByName(nameOrID)
+// markManuallyStopped 在容器记录中标记容器被用户停止
+func markManuallyStopped(info *models.Info) error {
+	_, err := models.UpdateContainerInfo(info.Id, func(latest *models.Info) bool {
+		latest.ManuallyStopped = true
+		return true
+	})
+	info.ManuallyStopped = true
+	return err
 }
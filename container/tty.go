@@ -0,0 +1,255 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/phper95/tinydocker/pkg/logger"
+)
+
+// ttyDrainTimeout 容器退出后等待 pty 中剩余输出复制到宿主机终端的最长时间
+const ttyDrainTimeout = time.Second
+
+// winsize 即内核的 struct winsize，syscall 包中没有定义
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+// console 交互式容器（-it）使用的终端：宿主机终端进入 raw 模式，输入输出在宿主机终端和 pty 之间复制，
+// 宿主机终端的窗口大小变化（SIGWINCH）同步到 pty。前台容器自动重启时每次启动分配新的 pty，console 保持不变
+type console struct {
+	mu     sync.Mutex
+	master *os.File
+	// output 当前 pty 的输出全部复制完成后关闭
+	output chan struct{}
+
+	restore func()
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+// newConsole 将宿主机终端设置为 raw 模式，并开始转发输入和窗口大小变化，标准输入不是终端时只转发输入
+func newConsole() (*console, error) {
+	c := &console{
+		restore: func() {},
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+	}
+	if isTerminal(int(os.Stdin.Fd())) {
+		restore, err := setRawTerminal(int(os.Stdin.Fd()))
+		if err != nil {
+			return nil, fmt.Errorf("set terminal raw mode error: %v", err)
+		}
+		c.restore = restore
+	}
+	signal.Notify(c.signals, syscall.SIGWINCH)
+	go c.forwardResize()
+	go c.forwardInput()
+	return c, nil
+}
+
+// openPty 分配新的 pty 并开始复制输出，返回的 slave 作为容器进程的标准输入输出和控制终端，
+// 进程启动后调用方需要关闭自己持有的 slave，进程退出后读取 master 才会结束
+func (c *console) openPty() (*os.File, error) {
+	master, slave, err := openPty()
+	if err != nil {
+		return nil, err
+	}
+	output := make(chan struct{})
+	c.mu.Lock()
+	old := c.master
+	c.master = master
+	c.output = output
+	c.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	c.resize()
+	go func() {
+		defer close(output)
+		// 所有 slave 关闭后读取 master 返回 EIO，视为输出结束
+		io.Copy(os.Stdout, master)
+	}()
+	return slave, nil
+}
+
+// drain 等待容器进程退出前的输出复制到宿主机终端
+func (c *console) drain() {
+	c.mu.Lock()
+	output := c.output
+	c.mu.Unlock()
+	if output == nil {
+		return
+	}
+	select {
+	case <-output:
+	case <-time.After(ttyDrainTimeout):
+	}
+}
+
+// Close 恢复宿主机终端并停止转发，读取标准输入的协程会一直阻塞到 CLI 退出
+func (c *console) Close() {
+	signal.Stop(c.signals)
+	close(c.done)
+	c.mu.Lock()
+	if c.master != nil {
+		c.master.Close()
+		c.master = nil
+	}
+	c.mu.Unlock()
+	c.restore()
+}
+
+// forwardResize 宿主机终端窗口大小变化时同步到 pty，内核随后向容器的前台进程组发送 SIGWINCH
+func (c *console) forwardResize() {
+	for {
+		select {
+		case <-c.signals:
+			c.resize()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// resize 将宿主机终端的窗口大小设置到当前的 pty
+func (c *console) resize() {
+	ws, err := getWinsize(int(os.Stdin.Fd()))
+	if err != nil {
+		// 标准输入不是终端
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.master == nil {
+		return
+	}
+	if err := fileIoctl(c.master, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(ws))); err != nil {
+		logger.Warn("Failed to resize pty: %v", err)
+	}
+}
+
+// forwardInput 将标准输入写入当前的 pty，标准输入结束时向 pty 写入 EOF 字符（Ctrl-D）
+func (c *console) forwardInput() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			c.write(buf[:n])
+		}
+		if err != nil {
+			c.write([]byte{4})
+			return
+		}
+	}
+}
+
+// write 写入当前的 pty，容器重启期间没有 pty 时丢弃输入
+func (c *console) write(p []byte) {
+	c.mu.Lock()
+	master := c.master
+	c.mu.Unlock()
+	if master != nil {
+		master.Write(p)
+	}
+}
+
+// openPty 通过 /dev/ptmx 分配一对 pty，两端都以 O_NOCTTY 打开，不会成为当前进程的控制终端
+func openPty() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open /dev/ptmx error: %v", err)
+	}
+	// 解锁 slave 并获取其编号
+	var unlock int32
+	if err := fileIoctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("unlock pty error: %v", err)
+	}
+	var n uint32
+	if err := fileIoctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("get pty number error: %v", err)
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("open pty slave error: %v", err)
+	}
+	return master, slave, nil
+}
+
+// setRawTerminal 将终端设置为 raw 模式（与 cfmakeraw 相同），按键原样发给容器，返回的函数用于恢复原来的设置
+func setRawTerminal(fd int) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&old))); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&raw))); err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&old)))
+	}, nil
+}
+
+// getWinsize 读取终端的窗口大小
+func getWinsize(fd int) (*winsize, error) {
+	ws := &winsize{}
+	if err := ioctl(uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(ws))); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// fileIoctl 对 f 执行 ioctl。f.Fd() 会把文件切换为阻塞模式，之后 Close 无法中断正在进行的读取，因此通过 SyscallConn 获取描述符
+func fileIoctl(f *os.File, req, arg uintptr) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ioctlErr error
+	if err := conn.Control(func(fd uintptr) {
+		ioctlErr = ioctl(fd, req, arg)
+	}); err != nil {
+		return err
+	}
+	return ioctlErr
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ttyProcAttr 让进程成为新会话的首进程，并将标准输入（pty 的 slave）设置为控制终端，
+// 这样容器内的 shell 才有作业控制，Ctrl-C 等按键由 pty 转换为信号发给前台进程组
+func ttyProcAttr(sys *syscall.SysProcAttr) *syscall.SysProcAttr {
+	if sys == nil {
+		sys = &syscall.SysProcAttr{}
+	}
+	sys.Setsid = true
+	sys.Setctty = true
+	sys.Ctty = 0
+	return sys
+}
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/phper95/tinydocker/cgroups"
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/pkg/logger"
+)
+
+// memoryLimitPattern memory.max 接受的取值：字节数，可带 k/m/g 单位，或 max 表示不限制
+var memoryLimitPattern = regexp.MustCompile(`^(max|[0-9]+[kKmMgG]?)$`)
+
+// UpdateConfig update 命令要修改的资源限制，为空（PidsLimit 为 nil）的字段保持不变
+type UpdateConfig struct {
+	MemoryLimit string
+	CpuLimit    string
+	PidsLimit   *int64 // 小于等于0表示不限制
+}
+
+// Rename 修改容器名称，新名称不能被其他容器使用
+func Rename(containerName, newName string) error {
+	// 全局锁保证名称检查和修改之间不会有同名的容器被创建
+	unlockAll, err := models.LockContainers()
+	if err != nil {
+		return err
+	}
+	defer unlockAll()
+	info, unlock, err := lockContainer(containerName)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerName, err)
+	}
+	defer unlock()
+	if info.Name == newName {
+		return fmt.Errorf("container %s is already named %s", models.TruncateID(info.Id), newName)
+	}
+	if err := checkNameAvailable(newName); err != nil {
+		return err
+	}
+
+	// 只修改名称，避免覆盖监控进程在此期间写入的状态
+	_, err = models.UpdateContainerInfo(info.Id, func(latest *models.Info) bool {
+		latest.Name = newName
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename container %s: %v", containerName, err)
+	}
+	logger.Info("Container %s renamed to %s", containerName, newName)
+	return nil
+}
+
+// Update 修改容器的资源限制：运行中的容器直接改写 cgroup 文件立即生效，
+// 新的限制同时保存在容器记录中，容器重启后继续使用
+func Update(containerName string, config *UpdateConfig) error {
+	if config.MemoryLimit == "" && config.CpuLimit == "" && config.PidsLimit == nil {
+		return fmt.Errorf("you must provide one or more flags when using this command")
+	}
+	if config.MemoryLimit != "" && !memoryLimitPattern.MatchString(config.MemoryLimit) {
+		return fmt.Errorf("invalid memory limit %q", config.MemoryLimit)
+	}
+	if config.CpuLimit != "" {
+		if _, _, err := cgroups.ParseCPUs(config.CpuLimit); err != nil {
+			return err
+		}
+	}
+
+	info, unlock, err := lockContainer(containerName)
+	if err != nil {
+		return fmt.Errorf("failed to find container %s: %v", containerName, err)
+	}
+	defer unlock()
+
+	// 已停止的容器没有 cgroup，只保存配置，下次启动时生效
+	if isContainerActive(info) {
+		cg := cgroups.NewCGroupManager(cgroups.ContainerCgroupName(info.Id))
+		if config.MemoryLimit != "" {
+			if err := cg.SetMemoryLimit(config.MemoryLimit); err != nil {
+				return fmt.Errorf("failed to update memory limit of container %s: %v", containerName, err)
+			}
+		}
+		if config.CpuLimit != "" {
+			if err := cg.SetCPULimit(config.CpuLimit); err != nil {
+				return fmt.Errorf("failed to update cpu limit of container %s: %v", containerName, err)
+			}
+		}
+		if config.PidsLimit != nil {
+			if err := cg.SetPidsLimit(*config.PidsLimit); err != nil {
+				return fmt.Errorf("failed to update pids limit of container %s: %v", containerName, err)
+			}
+		}
+	}
+
+	_, err = models.UpdateContainerInfo(info.Id, func(latest *models.Info) bool {
+		if config.MemoryLimit != "" {
+			latest.MemoryLimit = config.MemoryLimit
+		}
+		if config.CpuLimit != "" {
+			latest.CpuLimit = config.CpuLimit
+		}
+		if config.PidsLimit != nil {
+			latest.PidsLimit = *config.PidsLimit
+			if latest.PidsLimit < 0 {
+				latest.PidsLimit = 0
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save container %s config: %v", containerName, err)
+	}
+	logger.Info("Container %s updated", containerName)
+	return nil
+}
+
+// isContainerActive 容器的 init 进程是否仍在运行，此时容器的 cgroup 存在
+func isContainerActive(info *models.Info) bool {
+	switch info.State {
+	case models.ContainerStateCreated, models.ContainerStateRunning, models.ContainerStatePaused:
+		return info.Pid > 0 && models.IsContainerProcessAlive(info)
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 容器内的用户和组数据库，init 进程在 pivot_root 之后读取，因此是镜像中的文件
+const (
+	containerPasswdPath = "/etc/passwd"
+	containerGroupPath  = "/etc/group"
+)
+
+// execUser 解析后的容器进程运行用户
+type execUser struct {
+	Uid   int
+	Gid   int
+	Sgids []int  // 附加组
+	Home  string // 用户主目录，用于设置 HOME 环境变量
+}
+
+// passwdEntry /etc/passwd 中的一行 name:password:uid:gid:gecos:home:shell
+type passwdEntry struct {
+	Name string
+	Uid  int
+	Gid  int
+	Home string
+}
+
+// groupEntry /etc/group 中的一行 name:password:gid:member1,member2
+type groupEntry struct {
+	Name    string
+	Gid     int
+	Members []string
+}
+
+// lookupUser 根据容器内的 /etc/passwd 和 /etc/group 解析 <name|uid>[:<group|gid>]。
+// 与 docker 一致：数字ID在文件中不存在时直接使用，名称不存在时报错；
+// 未指定组时使用用户的主组，并加入 /etc/group 中包含该用户的所有组
+func lookupUser(user string) (*execUser, error) {
+	return lookupUserFromFiles(user, containerPasswdPath, containerGroupPath)
+}
+
+// lookupUserFromFiles 与 lookupUser 相同，用户和组从指定的 passwd、group 文件中查找
+func lookupUserFromFiles(user, passwdPath, groupPath string) (*execUser, error) {
+	passwd, err := parsePasswdFile(passwdPath)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := parseGroupFile(groupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	userPart, groupPart, _ := strings.Cut(user, ":")
+	if userPart == "" {
+		userPart = "0"
+	}
+	u := &execUser{Home: "/"}
+	uid, numeric := parseID(userPart)
+	found := false
+	var name string
+	for _, p := range passwd {
+		if (numeric && p.Uid == uid) || (!numeric && p.Name == userPart) {
+			u.Uid, u.Gid, u.Home, name = p.Uid, p.Gid, p.Home, p.Name
+			found = true
+			break
+		}
+	}
+	if !found {
+		if !numeric {
+			return nil, fmt.Errorf("unable to find user %s: no matching entries in passwd file", userPart)
+		}
+		u.Uid = uid
+	}
+
+	if groupPart != "" {
+		gid, numeric := parseID(groupPart)
+		found := false
+		for _, g := range groups {
+			if (numeric && g.Gid == gid) || (!numeric && g.Name == groupPart) {
+				u.Gid = g.Gid
+				found = true
+				break
+			}
+		}
+		if !found {
+			if !numeric {
+				return nil, fmt.Errorf("unable to find group %s: no matching entries in group file", groupPart)
+			}
+			u.Gid = gid
+		}
+	}
+
+	if name != "" {
+		for _, g := range groups {
+			for _, m := range g.Members {
+				if m == name && g.Gid != u.Gid {
+					u.Sgids = append(u.Sgids, g.Gid)
+					break
+				}
+			}
+		}
+	}
+	return u, nil
+}
+
+// parseID 解析非负的数字ID
+func parseID(s string) (int, bool) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// parsePasswdFile 解析 passwd 文件，文件不存在时返回空列表，格式错误的行会被忽略
+func parsePasswdFile(path string) ([]passwdEntry, error) {
+	var entries []passwdEntry
+	err := readColonFile(path, func(fields []string) {
+		if len(fields) < 7 {
+			return
+		}
+		uid, ok := parseID(fields[2])
+		if !ok {
+			return
+		}
+		gid, ok := parseID(fields[3])
+		if !ok {
+			return
+		}
+		entries = append(entries, passwdEntry{Name: fields[0], Uid: uid, Gid: gid, Home: fields[5]})
+	})
+	return entries, err
+}
+
+// parseGroupFile 解析 group 文件，文件不存在时返回空列表，格式错误的行会被忽略
+func parseGroupFile(path string) ([]groupEntry, error) {
+	var entries []groupEntry
+	err := readColonFile(path, func(fields []string) {
+		if len(fields) < 4 {
+			return
+		}
+		gid, ok := parseID(fields[2])
+		if !ok {
+			return
+		}
+		var members []string
+		if fields[3] != "" {
+			members = strings.Split(fields[3], ",")
+		}
+		entries = append(entries, groupEntry{Name: fields[0], Gid: gid, Members: members})
+	})
+	return entries, err
+}
+
+// readColonFile 逐行读取以冒号分隔的文件，跳过空行和注释
+func readColonFile(path string, fn func(fields []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fn(strings.Split(line, ":"))
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,190 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	testPasswd = `root:x:0:0:root:/root:/bin/sh
+# 注释和格式错误的行会被忽略
+nobody:x:65534:65534:nobody:/nonexistent:/bin/false
+app:x:1000:1000:app:/home/app:/bin/sh
+broken:x:abc:1000::/home/broken:/bin/sh
+`
+	testGroup = `root:x:0:
+wheel:x:10:app,root
+docker:x:999:app
+app:x:1000:
+`
+)
+
+// writeUserFiles 在临时目录中写入 passwd 和 group 文件，内容为空时不创建对应文件
+func writeUserFiles(t *testing.T, passwd, group string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	passwdPath := filepath.Join(dir, "passwd")
+	groupPath := filepath.Join(dir, "group")
+	if passwd != "" {
+		if err := os.WriteFile(passwdPath, []byte(passwd), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if group != "" {
+		if err := os.WriteFile(groupPath, []byte(group), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return passwdPath, groupPath
+}
+
+func TestLookupUser(t *testing.T) {
+	passwd, group := writeUserFiles(t, testPasswd, testGroup)
+	tests := map[string]execUser{
+		"":           {Uid: 0, Gid: 0, Sgids: []int{10}, Home: "/root"},
+		"root":       {Uid: 0, Gid: 0, Sgids: []int{10}, Home: "/root"},
+		"app":        {Uid: 1000, Gid: 1000, Sgids: []int{10, 999}, Home: "/home/app"},
+		"1000":       {Uid: 1000, Gid: 1000, Sgids: []int{10, 999}, Home: "/home/app"},
+		"app:docker": {Uid: 1000, Gid: 999, Sgids: []int{10}, Home: "/home/app"},
+		"app:10":     {Uid: 1000, Gid: 10, Sgids: []int{999}, Home: "/home/app"},
+		":10":        {Uid: 0, Gid: 10, Home: "/root"},
+		// 文件中不存在的数字ID直接使用，没有附加组
+		"1234":      {Uid: 1234, Home: "/"},
+		"1234:5678": {Uid: 1234, Gid: 5678, Home: "/"},
+		"app:5678":  {Uid: 1000, Gid: 5678, Sgids: []int{10, 999}, Home: "/home/app"},
+	}
+	for user, want := range tests {
+		got, err := lookupUserFromFiles(user, passwd, group)
+		if err != nil {
+			t.Errorf("lookupUser(%q) error: %v", user, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("lookupUser(%q) = %+v, want %+v", user, *got, want)
+		}
+	}
+
+	for _, user := range []string{"nouser", "app:nogroup", "broken", "-1"} {
+		if got, err := lookupUserFromFiles(user, passwd, group); err == nil {
+			t.Errorf("lookupUser(%q) = %+v, want error", user, *got)
+		}
+	}
+}
+
+// 镜像中没有 /etc/passwd 和 /etc/group 时只能使用数字ID
+func TestLookupUserWithoutFiles(t *testing.T) {
+	passwd, group := writeUserFiles(t, "", "")
+	for user, want := range map[string]execUser{
+		"":          {Home: "/"},
+		"0":         {Home: "/"},
+		"1000:1000": {Uid: 1000, Gid: 1000, Home: "/"},
+	} {
+		got, err := lookupUserFromFiles(user, passwd, group)
+		if err != nil {
+			t.Errorf("lookupUser(%q) error: %v", user, err)
+		} else if !reflect.DeepEqual(*got, want) {
+			t.Errorf("lookupUser(%q) = %+v, want %+v", user, *got, want)
+		}
+	}
+	for _, user := range []string{"root", "1000:staff"} {
+		if _, err := lookupUserFromFiles(user, passwd, group); err == nil {
+			t.Errorf("lookupUser(%q) succeeded, want error", user)
+		}
+	}
+}
+
+// setUserHelperEnv 设置时测试进程作为子进程运行 setUser，切换用户后无法恢复，不能在测试进程中执行
+const setUserHelperEnv = "TINYDOCKER_TEST_SETUSER"
+
+// credentials 子进程切换用户后的身份
+type credentials struct {
+	Uid    int
+	Gid    int
+	Groups []int
+}
+
+func TestSetUser(t *testing.T) {
+	if os.Getenv(setUserHelperEnv) != "" {
+		runSetUserHelper()
+		return
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("setUser requires root")
+	}
+	passwd, group := writeUserFiles(t, testPasswd, testGroup)
+	tests := []struct {
+		user           string
+		additionalGids string
+		want           credentials
+	}{
+		// 附加组来自 group 文件中包含该用户的组，再加上额外指定的组
+		{user: "app", want: credentials{Uid: 1000, Gid: 1000, Groups: []int{10, 999}}},
+		{user: "app:docker", additionalGids: "4242", want: credentials{Uid: 1000, Gid: 999, Groups: []int{10, 4242}}},
+		// 不在文件中的用户不会继承宿主机的附加组
+		{user: "1234:1234", want: credentials{Uid: 1234, Gid: 1234, Groups: []int{}}},
+	}
+	for _, tt := range tests {
+		outPath := filepath.Join(t.TempDir(), "credentials.json")
+		cmd := exec.Command(os.Args[0], "-test.run=^TestSetUser$")
+		cmd.Env = append(os.Environ(), setUserHelperEnv+"="+tt.user,
+			"TINYDOCKER_TEST_PASSWD="+passwd, "TINYDOCKER_TEST_GROUP="+group,
+			"TINYDOCKER_TEST_GIDS="+tt.additionalGids, "TINYDOCKER_TEST_OUT="+outPath)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("user %q: helper failed: %v\n%s", tt.user, err, out)
+		}
+		out, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got credentials
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatalf("user %q: parse helper output %q: %v", tt.user, out, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("user %q: credentials = %+v, want %+v", tt.user, got, tt.want)
+		}
+	}
+}
+
+// runSetUserHelper 在子进程中解析用户并切换，将切换后的身份以 JSON 写入文件后退出
+func runSetUserHelper() {
+	user, err := lookupUserFromFiles(os.Getenv(setUserHelperEnv), os.Getenv("TINYDOCKER_TEST_PASSWD"), os.Getenv("TINYDOCKER_TEST_GROUP"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var gids []uint32
+	if s := os.Getenv("TINYDOCKER_TEST_GIDS"); s != "" {
+		var gid uint32
+		fmt.Sscan(s, &gid)
+		gids = append(gids, gid)
+	}
+	// 切换用户后没有权限在临时目录中创建文件，先打开输出文件
+	out, err := os.Create(os.Getenv("TINYDOCKER_TEST_OUT"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := setUser(user, gids); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if groups == nil {
+		groups = []int{}
+	}
+	if err := json.NewEncoder(out).Encode(credentials{Uid: os.Getuid(), Gid: os.Getgid(), Groups: groups}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
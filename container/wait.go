@@ -0,0 +1,74 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phper95/tinydocker/container/models"
+	"os"
+	"time"
+)
+
+// waitPollInterval 轮询容器状态的时间间隔
+const waitPollInterval = 100 * time.Millisecond
+
+// Wait 阻塞直到容器退出，返回容器的退出码
+func Wait(containerName string) (int, error) {
+	info, err := ResolveContainer(containerName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find container %s: %v", containerName, err)
+	}
+	containerID := info.Id
+	var deadSince time.Time
+	for {
+		info, err = models.GetContainerInfo(containerID)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return 0, err
+			}
+			// 使用 --rm 的容器退出后被自动删除，读取删除前保存的退出码
+			if exitCode, err := models.GetRemovedExit(containerID); err == nil {
+				return exitCode, nil
+			}
+			return 0, fmt.Errorf("container %s has been removed", containerName)
+		}
+		if info.State == models.ContainerStateStopped {
+			return info.ExitCode, nil
+		}
+		// 进程已经退出但一直没有监控进程记录退出状态，按进程表校正容器状态
+		if info.State == models.ContainerStateRunning && !models.IsContainerProcessAlive(info) {
+			if deadSince.IsZero() {
+				deadSince = time.Now()
+			} else if time.Since(deadSince) > monitorRecordTimeout && models.ReconcileContainerState(info) {
+				// 监控进程仍在清理资源时不会校正，继续等待它写入 stopped
+				return info.ExitCode, nil
+			}
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// waitContainerStopped 轮询容器记录直到容器状态变为 stopped，timeout 为0时一直等待。
+// 容器的退出状态由监控进程在进程真正退出后写入。
+// 使用 --rm 的容器退出后会被监控进程删除，此时返回的 info 为 nil
+func waitContainerStopped(containerID string, timeout time.Duration) (*models.Info, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		info, err := models.GetContainerInfo(containerID)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if info.State == models.ContainerStateStopped {
+			return info, nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return info, fmt.Errorf("timed out waiting for container %s to exit", containerID)
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
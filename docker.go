@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/phper95/tinydocker/pkg/db"
 	"log"
 	"os"
 
@@ -19,31 +18,35 @@ func init() {
 }
 func main() {
 	log.Println("tinydocker start")
-	if !isInitProcess() {
-		InitBoltDB()
-		defer func() {
-			err := db.GetBoltDBClient(db.DefaultBoltDBClientName).Close()
-			if err != nil {
-				logger.Error("close bolt db error", err)
-			}
-		}()
-	}
-
 	app := cli.NewApp()
 	app.Name = enum.AppName
 	app.Usage = "A simple container runtime"
 	app.Version = "0.1.0"
 	app.Commands = []cli.Command{
 		commands.InitCommand,
+		commands.ShimCommand,
 		commands.RunCommand,
+		commands.CreateCommand,
 		commands.ExportCommand,
+		commands.ImportCommand,
+		commands.ImagesCommand,
 		commands.PsCommand,
+		commands.InspectCommand,
 		commands.LogsCommand,
 		commands.ExecCommand,
 		commands.ExecContainerCommand,
 		commands.StopCommand,
+		commands.KillCommand,
+		commands.StartCommand,
+		commands.RestartCommand,
+		commands.WaitCommand,
+		commands.PauseCommand,
+		commands.UnpauseCommand,
 		commands.RemoveCommand,
+		commands.RenameCommand,
+		commands.UpdateCommand,
 		commands.NetworkCommand,
+		commands.RuntimeCommand,
 	}
 
 	// 使用 cli.Run 执行命令
@@ -52,25 +55,3 @@ func main() {
 		panic(err)
 	}
 }
-
-// 判断是否为 init 进程
-func isInitProcess() bool {
-	return len(os.Args) > 1 && os.Args[1] == "init"
-}
-
-func InitBoltDB() {
-	err := db.InitBoltDBClient(db.DefaultBoltDBClientName, enum.DefaultNetworkDBPath)
-	if err != nil {
-		logger.Error("init bolt db error", err)
-		panic(err)
-	}
-	err = db.GetBoltDBClient(db.DefaultBoltDBClientName).CreateBucketIfNotExists(enum.DefaultNetworkTable)
-	if err != nil {
-		logger.Error("create network table error", err)
-	}
-	err = db.GetBoltDBClient(db.DefaultBoltDBClientName).CreateBucketIfNotExists(enum.AllocatedIPKey)
-	if err != nil {
-		logger.Error("create allocated ip table error", err)
-	}
-	log.Println("init bolt db finished", db.DefaultBoltDBClientName)
-}
@@ -1,9 +1,10 @@
 package enum
 
 const (
-	AppName               = "tinydocker"
-	ContainerStateRunning = "running"
-	DefaultNetworkDBPath  = "/var/lib/tinydocker/network/files/local-kv.db"
-	DefaultNetworkTable   = "tinydocker_network"
-	AllocatedIPKey        = "allocated_ip"
+	AppName                = "tinydocker"
+	ContainerStateRunning  = "running"
+	DefaultNetworkDBPath   = "/var/lib/tinydocker/network/files/local-kv.db"
+	DefaultNetworkTable    = "tinydocker_network"
+	DefaultContainerDBPath = "/var/lib/tinydocker/containers.db"
+	AllocatedIPKey         = "allocated_ip"
 )
@@ -6,6 +6,8 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -96,3 +98,67 @@ func UnmountOverlayFS(containerDir, mountPoint string) error {
 	logger.Debug("OverlayFS unmounted successfully")
 	return err
 }
+
+// UnmountOverlay 仅卸载 OverlayFS，保留 upper 和 work 目录，容器再次启动时可以继续使用之前的文件修改
+func UnmountOverlay(mountPoint string) error {
+	if err := syscall.Unmount(mountPoint, 0); err != nil {
+		logger.Error("failed to unmount overlayfs: %v", err)
+		return fmt.Errorf("failed to unmount overlayfs: %w", err)
+	}
+	logger.Debug("OverlayFS unmounted successfully")
+	return nil
+}
+
+// IsMounted 判断路径是否为挂载点（通过比较与父目录的设备号）
+func IsMounted(target string) bool {
+	st, err := os.Stat(target)
+	if err != nil {
+		return false
+	}
+	parent, err := os.Stat(path.Dir(target))
+	if err != nil {
+		return false
+	}
+	return st.Sys().(*syscall.Stat_t).Dev != parent.Sys().(*syscall.Stat_t).Dev
+}
+
+// MountsUnder 返回 /proc/self/mountinfo 中位于 dir 及其子目录下的挂载点，按挂载顺序排列（外层在前）
+func MountsUnder(dir string) ([]string, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("read mountinfo error: %w", err)
+	}
+	dir = path.Clean(dir)
+	var mounts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		// 第5列为挂载点，空格等特殊字符以 \040 这样的八进制转义
+		target := unescapeMountPath(fields[4])
+		if target == dir || strings.HasPrefix(target, dir+"/") {
+			mounts = append(mounts, target)
+		}
+	}
+	return mounts, nil
+}
+
+// unescapeMountPath 还原 mountinfo 中八进制转义的路径
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -17,7 +17,7 @@ func Export(containerName, output string) error {
 		return fmt.Errorf("container name cannot be empty")
 	}
 
-	containerInfo, err := container.GetContainerInfoByName(containerName)
+	containerInfo, err := container.ResolveContainer(containerName)
 	if err != nil {
 		logger.Error("get container info: ", err)
 		return fmt.Errorf("get container info: %w", err)
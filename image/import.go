@@ -0,0 +1,144 @@
+package image
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phper95/tinydocker/container/models"
+	"github.com/phper95/tinydocker/pkg/filters"
+	"github.com/phper95/tinydocker/pkg/logger"
+)
+
+// FilterKeys images 和镜像列表接口支持的过滤条件
+var FilterKeys = []string{"label", "reference"}
+
+// imageNamePattern 镜像名会作为 tar 包和解压目录的文件名使用
+var imageNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Image 本地镜像
+type Image struct {
+	Name      string            `json:"name"`
+	Size      int64             `json:"size"`
+	CreatedAt string            `json:"created_at"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Env       []string          `json:"env,omitempty"`
+}
+
+// Import 将根文件系统 tar 包导入为本地镜像，labels 和默认环境变量 env（KEY=VALUE）保存在镜像配置中
+func Import(tarPath, name string, labels map[string]string, env []string) error {
+	if !imageNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid image name %q", name)
+	}
+	for _, e := range env {
+		key, _, hasValue := strings.Cut(e, "=")
+		if !hasValue || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", e)
+		}
+	}
+	dstTar := models.GetImageTarPath(name)
+	if _, err := os.Stat(dstTar); err == nil {
+		return fmt.Errorf("image %s already exists", name)
+	}
+	if err := copyFile(tarPath, dstTar); err != nil {
+		return fmt.Errorf("import image %s error: %w", name, err)
+	}
+	config, err := models.ReadImageConfig(name)
+	if err != nil {
+		os.Remove(dstTar)
+		return err
+	}
+	config.Labels = labels
+	config.Env = env
+	if err := models.WriteImageConfig(name, config); err != nil {
+		os.Remove(dstTar)
+		return err
+	}
+	logger.Info("image %s imported from %s", name, tarPath)
+	return nil
+}
+
+// List 返回满足过滤条件的本地镜像，按名称排序
+func List(args filters.Args) ([]*Image, error) {
+	paths, err := filepath.Glob(filepath.Join(models.DefaultImageTarDir, "*"+models.DefaultImageTarSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var images []*Image
+	for _, path := range paths {
+		img, err := loadImage(strings.TrimSuffix(filepath.Base(path), models.DefaultImageTarSuffix))
+		if err != nil {
+			continue
+		}
+		if !args.Match("reference", img.Name) || !args.MatchLabels(img.Labels) {
+			continue
+		}
+		images = append(images, img)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Name < images[j].Name })
+	return images, nil
+}
+
+// Inspect 返回镜像的详细信息
+func Inspect(name string) (*Image, error) {
+	img, err := loadImage(name)
+	if err != nil {
+		return nil, fmt.Errorf("image %s not found", name)
+	}
+	return img, nil
+}
+
+// loadImage 读取镜像 tar 包信息和镜像配置
+func loadImage(name string) (*Image, error) {
+	fi, err := os.Stat(models.GetImageTarPath(name))
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("image %s is not a regular file", name)
+	}
+	config, err := models.ReadImageConfig(name)
+	if err != nil {
+		logger.Warn("read image %s config error: %v", name, err)
+		config = &models.ImageConfig{}
+	}
+	return &Image{
+		Name:      name,
+		Size:      fi.Size(),
+		CreatedAt: fi.ModTime().Format(time.DateTime),
+		Labels:    config.Labels,
+		Env:       config.Env,
+	}, nil
+}
+
+// copyFile 先写入临时文件再重命名，避免导入中断时留下不完整的镜像
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
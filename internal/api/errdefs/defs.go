@@ -16,6 +16,9 @@ const (
 	// 容器已停止（无法执行启动外的操作，如暂停）
 	ErrContainerStopped = "ErrContainerStopped"
 
+	// 容器未处于暂停状态（无法恢复）
+	ErrContainerNotPaused = "ErrContainerNotPaused"
+
 	// 容器配置无效（如端口格式错误、命令不存在）
 	ErrInvalidContainerConfig = "ErrInvalidContainerConfig"
 
@@ -1,32 +1,91 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/phper95/tinydocker/container"
 	"github.com/phper95/tinydocker/container/models"
 	"github.com/phper95/tinydocker/internal/api/errdefs"
 	"github.com/phper95/tinydocker/internal/api/types"
 	"net/http"
-	"path/filepath"
 )
 
-// ListContainers 列出所有容器
+// ListContainers 列出所有容器，支持 ?filter=status=running 等过滤条件
 func ListContainers(c *gin.Context) {
-	containers := models.ReadContainersInfo()
+	args, err := models.ParseContainerFilters(c.QueryArray("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Error(errdefs.ErrInvalidParameter, "过滤条件无效", err.Error()))
+		return
+	}
+	containers := models.FilterContainers(models.ReadContainersInfoByLabels(args.Get("label")), true, args)
 	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, containers, nil))
 }
 
+// GetContainerInfo 获取容器详情，id 可以是容器ID、唯一的ID前缀或容器名称
 func GetContainerInfo(c *gin.Context) {
+	info, ok := resolveContainer(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, info, nil))
+}
+
+// PauseContainer 暂停容器
+func PauseContainer(c *gin.Context) {
+	info, ok := resolveContainer(c)
+	if !ok {
+		return
+	}
+	if err := container.Pause(info.Id); err != nil {
+		writeContainerError(c, errdefs.ErrContainerStopped, "暂停容器失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, nil, nil))
+}
+
+// UnpauseContainer 恢复被暂停的容器
+func UnpauseContainer(c *gin.Context) {
+	info, ok := resolveContainer(c)
+	if !ok {
+		return
+	}
+	if err := container.Unpause(info.Id); err != nil {
+		writeContainerError(c, errdefs.ErrContainerNotPaused, "恢复容器失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, nil, nil))
+}
+
+// resolveContainer 根据路径参数 id 查找容器，找不到或不唯一时写入错误响应并返回 false
+func resolveContainer(c *gin.Context) (*models.Info, bool) {
 	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, types.Error(errdefs.ErrInvalidContainerID, "容器id无效", "容器id不能为空"))
-		return
+		return nil, false
 	}
-	// id 是容器ID，需要拼接配置文件路径
-	filePath := filepath.Join(models.DefaultContainerInfoPath, id, models.DefaultContainerInfoFileName)
-	info, err := models.ReadContainerInfo(filePath)
-	if err != nil {
+	info, err := container.ResolveContainer(id)
+	switch {
+	case err == nil:
+		return info, true
+	case errors.Is(err, container.ErrAmbiguousContainer):
+		c.JSON(http.StatusBadRequest, types.Error(errdefs.ErrInvalidContainerID, "容器id不唯一", err.Error()))
+	case errors.Is(err, container.ErrContainerNotFound):
 		c.JSON(http.StatusNotFound, types.Error(errdefs.ErrContainerNotFound, "容器不存在", err.Error()))
-		return
+	default:
+		c.JSON(http.StatusInternalServerError, types.Error(errdefs.ErrContainerNotFound, "获取容器失败", err.Error()))
+	}
+	return nil, false
+}
+
+// writeContainerError 按错误类型写入容器操作失败的响应：容器不存在返回 404，
+// 容器状态不允许该操作返回 409（错误码为 stateCode），其他错误返回 500
+func writeContainerError(c *gin.Context, stateCode, message string, err error) {
+	switch {
+	case errors.Is(err, container.ErrContainerNotFound):
+		c.JSON(http.StatusNotFound, types.Error(errdefs.ErrContainerNotFound, "容器不存在", err.Error()))
+	case errors.Is(err, container.ErrInvalidContainerState):
+		c.JSON(http.StatusConflict, types.Error(stateCode, message, err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, types.Error(stateCode, message, err.Error()))
 	}
-	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, info, nil))
 }
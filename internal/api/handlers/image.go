@@ -2,10 +2,24 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/phper95/tinydocker/image"
+	"github.com/phper95/tinydocker/internal/api/errdefs"
 	"github.com/phper95/tinydocker/internal/api/types"
+	"github.com/phper95/tinydocker/pkg/filters"
 	"net/http"
 )
 
+// ListImages 列出本地镜像，支持 ?filter=label=k=v 过滤
 func ListImages(c *gin.Context) {
-	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, "images", nil))
+	args, err := filters.Parse(c.QueryArray("filter"), image.FilterKeys...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Error(errdefs.ErrInvalidParameter, "过滤条件无效", err.Error()))
+		return
+	}
+	images, err := image.List(args)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Error(errdefs.ErrImageNotFound, "获取镜像列表失败", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, images, nil))
 }
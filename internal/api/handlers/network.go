@@ -2,10 +2,25 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/phper95/tinydocker/internal/api/errdefs"
 	"github.com/phper95/tinydocker/internal/api/types"
+	"github.com/phper95/tinydocker/network"
+	"github.com/phper95/tinydocker/pkg/filters"
 	"net/http"
 )
 
+// ListNetworks 列出网络，支持 ?filter=label=k=v 过滤
 func ListNetworks(c *gin.Context) {
-	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, "networks", nil))
+	args, err := filters.Parse(c.QueryArray("filter"), network.NetworkFilterKeys...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.Error(errdefs.ErrInvalidParameter, "过滤条件无效", err.Error()))
+		return
+	}
+	// network 包在每次操作期间打开网络数据库并按引用计数关闭，并发请求共享同一个连接
+	networks, err := network.ListNetworks(args)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.Error(errdefs.ErrNetworkNotFound, "获取网络列表失败", err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, types.Success(types.ApiVersionV1, networks, nil))
 }
@@ -63,6 +63,8 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			containers.GET("list", middleware.RequirePermission("containers", "list"), handlers.ListContainers)
 			containers.GET("/:id", middleware.RequirePermission("containers", "get"), handlers.GetContainerInfo)
+			containers.POST("/:id/pause", middleware.RequirePermission("containers", "pause"), handlers.PauseContainer)
+			containers.POST("/:id/unpause", middleware.RequirePermission("containers", "unpause"), handlers.UnpauseContainer)
 			// containers.POST("create", handlers.CreateContainer)
 			// containers.POST("/:id/start", handlers.StartContainer)
 			// containers.POST("/:id/stop", handlers.StopContainer)
@@ -445,6 +445,8 @@ func (s *AuthService) initDefaultPermissions() {
 		{ID: "containers:delete", Name: "删除容器", Description: "删除容器", Resource: "containers", Action: "delete"},
 		{ID: "containers:start", Name: "启动容器", Description: "启动容器", Resource: "containers", Action: "start"},
 		{ID: "containers:stop", Name: "停止容器", Description: "停止容器", Resource: "containers", Action: "stop"},
+		{ID: "containers:pause", Name: "暂停容器", Description: "冻结容器内的所有进程", Resource: "containers", Action: "pause"},
+		{ID: "containers:unpause", Name: "恢复容器", Description: "解冻被暂停的容器", Resource: "containers", Action: "unpause"},
 		{ID: "images:list", Name: "列出镜像", Description: "查看镜像列表", Resource: "images", Action: "list"},
 		{ID: "images:create", Name: "创建镜像", Description: "构建或导入镜像", Resource: "images", Action: "create"},
 		{ID: "images:get", Name: "查看镜像", Description: "查看镜像详情", Resource: "images", Action: "get"},
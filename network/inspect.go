@@ -0,0 +1,69 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/phper95/tinydocker/container/models"
+)
+
+// NetworkInspect inspect 命令输出的网络详情
+type NetworkInspect struct {
+	Name      string                  `json:"name"`
+	Driver    string                  `json:"driver"`
+	Subnet    string                  `json:"subnet"`
+	Gateway   string                  `json:"gateway"`
+	Bridge    string                  `json:"bridge"` // 宿主机上的网桥设备名
+	Labels    map[string]string       `json:"labels"`
+	Endpoints []NetworkEndpointDetail `json:"endpoints"` // 当前连接到该网络的容器
+}
+
+// NetworkEndpointDetail 连接到网络的一个容器
+type NetworkEndpointDetail struct {
+	ContainerID   string   `json:"container_id"`
+	ContainerName string   `json:"container_name"`
+	IPAddress     string   `json:"ip_address"`
+	HostVeth      string   `json:"host_veth"`
+	PortMapping   []string `json:"port_mapping"`
+}
+
+// Inspect 返回网络配置和当前连接的容器
+func Inspect(name string) (*NetworkInspect, error) {
+	nw, err := GetNetworkFromDB(name)
+	if err != nil {
+		return nil, err
+	}
+	if nw == nil {
+		return nil, fmt.Errorf("network %s not exists", name)
+	}
+	result := &NetworkInspect{
+		Name:   nw.Name,
+		Driver: nw.Driver,
+		Labels: nw.Labels,
+		// bridge 驱动的网桥与网络同名
+		Bridge: nw.Name,
+	}
+	if nw.IPRange != nil {
+		// 网络的 IPRange.IP 是创建时分配给网桥的地址，即网关
+		subnet := &net.IPNet{IP: nw.IPRange.IP.Mask(nw.IPRange.Mask), Mask: nw.IPRange.Mask}
+		result.Subnet = subnet.String()
+		result.Gateway = nw.IPRange.IP.String()
+	}
+	for _, info := range models.ReadContainersInfo() {
+		if info.Endpoint == nil || info.Endpoint.Network != nw.Name {
+			continue
+		}
+		result.Endpoints = append(result.Endpoints, NetworkEndpointDetail{
+			ContainerID:   info.Id,
+			ContainerName: info.Name,
+			IPAddress:     info.Endpoint.IPAddress,
+			HostVeth:      info.Endpoint.HostVeth,
+			PortMapping:   info.PortMapping,
+		})
+	}
+	sort.Slice(result.Endpoints, func(i, j int) bool {
+		return result.Endpoints[i].ContainerName < result.Endpoints[j].ContainerName
+	})
+	return result, nil
+}
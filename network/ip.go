@@ -78,7 +78,7 @@ func AllocateIP(subnet *net.IPNet) (ip net.IP, err error) {
 		}
 	}
 	err = SaveIP()
-	fmt.Println("IP:", ip)
+	logger.Debug("allocated ip %s", ip)
 	return ip, err
 }
 
@@ -6,14 +6,15 @@ import (
 	"github.com/phper95/tinydocker/container/models"
 	"github.com/phper95/tinydocker/enum"
 	"github.com/phper95/tinydocker/pkg/db"
+	"github.com/phper95/tinydocker/pkg/filters"
 	"github.com/phper95/tinydocker/pkg/logger"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
-	"log"
 	"net"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -30,7 +31,12 @@ type Network struct {
 	Name    string
 	IPRange *net.IPNet
 	Driver  string
+	Labels  map[string]string // 用户自定义的元数据
 }
+
+// NetworkFilterKeys network ls 和网络列表接口支持的过滤条件
+var NetworkFilterKeys = []string{"label", "name", "driver"}
+
 type Endpoint struct {
 	ID          string           `json:"id"`
 	Device      netlink.Veth     `json:"device"`
@@ -44,10 +50,34 @@ const (
 	InterfaceLoName = "lo"
 )
 
+// openNetworkDB 打开网络数据库，返回的函数用于释放连接。导出的网络操作在执行期间持有数据库，
+// bbolt 的文件锁保证不同进程的IP分配等读-改-写依次进行；同一进程内嵌套调用共用一个连接
+func openNetworkDB() (func(), error) {
+	if err := db.InitBoltDBClient(db.DefaultBoltDBClientName, enum.DefaultNetworkDBPath); err != nil {
+		return nil, err
+	}
+	release := func() {
+		if err := db.CloseBoltDBClient(db.DefaultBoltDBClientName); err != nil {
+			logger.Error("close network db error: ", err)
+		}
+	}
+	if err := db.GetBoltDBClient("").CreateBucketIfNotExists(enum.DefaultNetworkTable); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
 // 1. 解析用户输入的子网信息，确保格式正确
 // 2. 调用指定的网络驱动创建网络
 // 3. 将网络信息保存到数据库中，便于后续管理
-func CreateNetwork(name, driver, subnet string) error {
+func CreateNetwork(name, driver, subnet string, labels map[string]string) error {
+	release, err := openNetworkDB()
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// 判断网络是否存在
 	nw, err := GetNetworkFromDB(name)
 	if err != nil {
@@ -87,32 +117,66 @@ func CreateNetwork(name, driver, subnet string) error {
 		logger.Error("create network error: ", err)
 		return err
 	}
+	nw.Labels = labels
 	return nw.Save()
 }
 
-func ListNetwork() {
+func ListNetwork(args filters.Args) {
+	networks, err := ListNetworks(args)
+	if err != nil {
+		logger.Error("list network error: ", err)
+		return
+	}
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
 	fmt.Fprintln(w, "NAME\tIPRANGE\tDRIVER")
+	for _, nw := range networks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", nw.Name, nw.IPRange.String(), nw.Driver)
+	}
+	if err := w.Flush(); err != nil {
+		logger.Error("list network error: ", err)
+	}
+	return
+}
+
+// ListNetworks 返回满足过滤条件的网络，按名称排序
+func ListNetworks(args filters.Args) ([]*Network, error) {
+	release, err := openNetworkDB()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	data, err := db.GetBoltDBClient("").GetAll(enum.DefaultNetworkTable)
 	if err != nil {
-		logger.Error("list network error: ", err)
-		return
+		return nil, err
 	}
-	for _, v := range data {
+	var networks []*Network
+	for k, v := range data {
+		// 同一个表中还保存了IP分配记录
+		if k == enum.AllocatedIPKey {
+			continue
+		}
 		nw := &Network{}
 		if err := json.Unmarshal(v, nw); err != nil {
 			logger.Error("list network error: ", err)
 			continue
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\n", nw.Name, nw.IPRange.String(), nw.Driver)
-	}
-	if err := w.Flush(); err != nil {
-		logger.Error("list network error: ", err)
+		if !args.Match("name", nw.Name) || !args.Match("driver", nw.Driver) || !args.MatchLabels(nw.Labels) {
+			continue
+		}
+		networks = append(networks, nw)
 	}
-	return
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	return networks, nil
 }
 
 func DeleteNetwork(name string) error {
+	release, err := openNetworkDB()
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	nw, err := GetNetworkFromDB(name)
 	if err != nil {
 		logger.Error("get network from db error: ", err)
@@ -162,6 +226,12 @@ func (nw *Network) Delete(name string) error {
 }
 
 func GetNetworkFromDB(name string) (network *Network, err error) {
+	release, err := openNetworkDB()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	data, err := db.GetBoltDBClient("").Get(enum.DefaultNetworkTable, name)
 	if err != nil {
 		logger.Error("load ip error: %v", err)
@@ -177,7 +247,14 @@ func GetNetworkFromDB(name string) (network *Network, err error) {
 	return
 }
 
-func Connect(name string, containerInfo *models.Info) (ip net.IP, err error) {
+// AllocateContainerIP 在指定网络中为容器分配一个IP，容器创建时调用，启动时通过 Connect 使用该IP
+func AllocateContainerIP(name string) (ip net.IP, err error) {
+	release, err := openNetworkDB()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	nw, err := GetNetworkFromDB(name)
 	if err != nil {
 		logger.Error("get network from db error: ", err)
@@ -196,7 +273,6 @@ func Connect(name string, containerInfo *models.Info) (ip net.IP, err error) {
 		return nil, err
 	}
 
-	fmt.Println("nw.IPRange=", nw.IPRange)
 	// 注意，这里需要解析子网
 	_, subnet, err := net.ParseCIDR(nw.IPRange.String())
 	if err != nil {
@@ -209,6 +285,40 @@ func Connect(name string, containerInfo *models.Info) (ip net.IP, err error) {
 		logger.Error("allocate ip error: ", err)
 		return
 	}
+	return ip, nil
+}
+
+// Connect 将容器连接到指定网络，容器已经分配过IP（containerInfo.IpAddress）时复用该IP
+func Connect(name string, containerInfo *models.Info) (ip net.IP, err error) {
+	release, err := openNetworkDB()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	nw, err := GetNetworkFromDB(name)
+	if err != nil {
+		logger.Error("get network from db error: ", err)
+		return
+	}
+	if nw == nil {
+		logger.Error("network %s not exists", name)
+		err = fmt.Errorf("network %s not exists", name)
+		return
+	}
+
+	logger.Debug("network %s ip range %s", name, nw.IPRange)
+	if containerInfo.IpAddress != "" {
+		ip = net.ParseIP(containerInfo.IpAddress).To4()
+	}
+	if ip == nil {
+		ip, err = AllocateContainerIP(name)
+		if err != nil {
+			return
+		}
+		// 立即记录新分配的IP，后续步骤失败时调用方通过 Disconnect 归还
+		containerInfo.IpAddress = ip.String()
+	}
 	logger.Info("Connect network: %+v, ip: %s", nw, ip.String())
 	// 创建网络端点
 	ep := &Endpoint{
@@ -228,6 +338,12 @@ func Connect(name string, containerInfo *models.Info) (ip net.IP, err error) {
 		logger.Error("connect network error: ", err)
 		return
 	}
+	// 记录宿主机上创建的资源，连接失败或容器停止时通过 Disconnect 撤销
+	containerInfo.Endpoint = &models.NetworkEndpoint{
+		Network:   name,
+		IPAddress: ip.String(),
+		HostVeth:  ep.Device.Name,
+	}
 
 	// 在容器网络命名空间中配置网络接口
 	err = configEndpointNetwork(ep, containerInfo)
@@ -271,7 +387,7 @@ func configEndpointNetwork(ep *Endpoint, containerInfo *models.Info) error {
 	defer configNetNs(&peerLink, containerInfo)()
 	ip := *ep.Network.IPRange
 	ip.IP = ep.IPAddress
-	log.Println("ip.string(): ", ip.String(), " ep.IPAddress: ", "ep.IPAddress", ip.IP.String())
+	logger.Debug("container ip %s, endpoint ip %s", ip.String(), ip.IP.String())
 
 	// 为端点设备的Peer接口设置IP地址
 	err = SetInterfaceIP(ep.Device.PeerName, ip.String())
@@ -379,7 +495,7 @@ func configNetNs(peerLink *netlink.Link, containerInfo *models.Info) func() {
 }
 
 // 配置宿主机到容器的端口映射
-// 通过iptables的DNAT规则来实现宿主机上的请求转发到容器上
+// 通过iptables的DNAT规则来实现宿主机上的请求转发到容器上，添加成功的规则记录在 containerInfo.Endpoint 中
 func configPortMapping(ep *Endpoint, containerInfo *models.Info) error {
 	for _, pm := range ep.PortMapping {
 		portMapping := strings.Split(pm, ":")
@@ -394,14 +510,86 @@ func configPortMapping(ep *Endpoint, containerInfo *models.Info) error {
 		// --dport：指定目标端口
 		// -j DNAT：指定目标地址转换
 		// --to-destination：指定目标地址和端口
-		iptablesCmd := fmt.Sprintf("-t nat -A PREROUTING -p tcp -m tcp  --dport %s -j DNAT --to-destination %s:%s",
+		rule := fmt.Sprintf("-p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
 			portMapping[0], ep.IPAddress.String(), portMapping[1])
-		output, err := exec.Command("iptables", strings.Split(iptablesCmd, " ")...).Output()
-		if err != nil {
-			logger.Error("add iptables rule error:", err, "output:", output)
+		if err := natRule("-A", rule); err != nil {
+			logger.Error("add iptables rule error: ", err)
 			continue
 		}
-		logger.Info("add iptables rule: %s", output)
+		containerInfo.Endpoint.NatRules = append(containerInfo.Endpoint.NatRules, rule)
+		logger.Info("add iptables rule: %s", rule)
 	}
 	return nil
 }
+
+// natRule 在 nat 表的 PREROUTING 链中添加（-A）或删除（-D）一条规则
+func natRule(action, rule string) error {
+	args := append([]string{"-t", "nat", action, "PREROUTING"}, strings.Fields(rule)...)
+	output, err := exec.Command("iptables", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("iptables %s: %v, output: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Disconnect 撤销容器在宿主机上的网络资源：删除端口映射规则和 veth 设备，释放容器IP。
+// 资源已经不存在时忽略，可以重复调用。调用方需要保存修改后的 containerInfo
+func Disconnect(containerInfo *models.Info) error {
+	var errs []string
+	if ep := containerInfo.Endpoint; ep != nil {
+		for _, rule := range ep.NatRules {
+			// 规则已经不存在时 iptables 返回错误，先检查再删除
+			if natRule("-C", rule) != nil {
+				continue
+			}
+			if err := natRule("-D", rule); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		// 容器的网络命名空间销毁时 veth 设备对通常已经被内核删除
+		if ep.HostVeth != "" {
+			if link, err := netlink.LinkByName(ep.HostVeth); err == nil {
+				if err := netlink.LinkDel(link); err != nil {
+					errs = append(errs, fmt.Sprintf("delete veth %s: %v", ep.HostVeth, err))
+				}
+			}
+		}
+	}
+
+	if containerInfo.IpAddress != "" && containerInfo.Network != "" {
+		if err := releaseContainerIP(containerInfo.Network, net.ParseIP(containerInfo.IpAddress)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	containerInfo.Endpoint = nil
+	containerInfo.IpAddress = ""
+	if len(errs) > 0 {
+		return fmt.Errorf("disconnect container %s: %s", containerInfo.Id, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// releaseContainerIP 将容器IP归还给所在网络，网络已经被删除时忽略
+func releaseContainerIP(name string, ip net.IP) error {
+	if ip == nil {
+		return nil
+	}
+	release, err := openNetworkDB()
+	if err != nil {
+		return err
+	}
+	defer release()
+	nw, err := GetNetworkFromDB(name)
+	if err != nil || nw == nil {
+		return err
+	}
+	if err := LoadIP(); err != nil {
+		return err
+	}
+	// 与 AllocateContainerIP 一致，使用子网的网络地址作为分配记录的键
+	_, subnet, err := net.ParseCIDR(nw.IPRange.String())
+	if err != nil {
+		return err
+	}
+	return ReleaseIP(subnet, ip)
+}
@@ -0,0 +1,190 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 参考 opencontainers/runtime-spec，这里只定义 tinydocker 支持的字段
+
+// Version 实现的 runtime-spec 版本
+const Version = "1.0.2"
+
+// SpecConfigFileName bundle 中的配置文件名
+const SpecConfigFileName = "config.json"
+
+// OCI 规定的容器状态
+const (
+	StateCreating = "creating"
+	StateCreated  = "created"
+	StateRunning  = "running"
+	StateStopped  = "stopped"
+)
+
+// Spec bundle 中 config.json 的内容
+type Spec struct {
+	Version     string            `json:"ociVersion"`
+	Process     *Process          `json:"process,omitempty"`
+	Root        *Root             `json:"root,omitempty"`
+	Hostname    string            `json:"hostname,omitempty"`
+	Mounts      []Mount           `json:"mounts,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Hooks       *Hooks            `json:"hooks,omitempty"`
+	Linux       *Linux            `json:"linux,omitempty"`
+}
+
+// Hooks 容器生命周期钩子，在宿主机上执行
+type Hooks struct {
+	Prestart  []Hook `json:"prestart,omitempty"`  // namespace 已创建、用户进程启动前
+	Poststart []Hook `json:"poststart,omitempty"` // 用户进程启动后
+	Poststop  []Hook `json:"poststop,omitempty"`  // 容器资源清理后
+}
+
+// Hook 钩子程序，Args 包含 argv[0]，Timeout 为超时秒数
+type Hook struct {
+	Path    string   `json:"path"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Timeout *int     `json:"timeout,omitempty"`
+}
+
+// Process 容器进程的配置
+type Process struct {
+	Terminal bool          `json:"terminal,omitempty"`
+	User     User          `json:"user"`
+	Args     []string      `json:"args,omitempty"`
+	Env      []string      `json:"env,omitempty"`
+	Cwd      string        `json:"cwd"`
+	Rlimits  []POSIXRlimit `json:"rlimits,omitempty"`
+}
+
+// User 容器进程的运行用户
+type User struct {
+	UID            uint32   `json:"uid"`
+	GID            uint32   `json:"gid"`
+	AdditionalGids []uint32 `json:"additionalGids,omitempty"`
+}
+
+// POSIXRlimit 进程的资源限制
+type POSIXRlimit struct {
+	Type string `json:"type"`
+	Hard uint64 `json:"hard"`
+	Soft uint64 `json:"soft"`
+}
+
+// Root 容器的根文件系统，Path 为相对 bundle 的路径或绝对路径
+type Root struct {
+	Path     string `json:"path"`
+	Readonly bool   `json:"readonly,omitempty"`
+}
+
+// Mount 容器内的挂载
+type Mount struct {
+	Destination string   `json:"destination"`
+	Type        string   `json:"type,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// Linux Linux 平台相关的配置，目前只支持资源限制
+type Linux struct {
+	Resources *LinuxResources `json:"resources,omitempty"`
+}
+
+// LinuxResources cgroup 资源限制
+type LinuxResources struct {
+	Memory *LinuxMemory `json:"memory,omitempty"`
+	CPU    *LinuxCPU    `json:"cpu,omitempty"`
+}
+
+// LinuxMemory 内存限制，单位字节
+type LinuxMemory struct {
+	Limit *int64 `json:"limit,omitempty"`
+}
+
+// LinuxCPU CPU 限制
+type LinuxCPU struct {
+	Quota  *int64  `json:"quota,omitempty"`
+	Period *uint64 `json:"period,omitempty"`
+}
+
+// State runtime state 命令输出的容器状态
+type State struct {
+	Version     string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Pid         int               `json:"pid,omitempty"`
+	Bundle      string            `json:"bundle"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// LoadSpec 读取并校验 bundle 目录中的 config.json
+func LoadSpec(bundle string) (*Spec, error) {
+	data, err := os.ReadFile(filepath.Join(bundle, SpecConfigFileName))
+	if err != nil {
+		return nil, fmt.Errorf("read bundle config error: %v", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("parse bundle config error: %v", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate 校验 tinydocker 运行容器必需的字段
+func (s *Spec) Validate() error {
+	if !strings.HasPrefix(s.Version, "1.") {
+		return fmt.Errorf("unsupported ociVersion %q", s.Version)
+	}
+	if s.Root == nil || s.Root.Path == "" {
+		return errors.New("root.path is required")
+	}
+	if s.Process == nil || len(s.Process.Args) == 0 {
+		return errors.New("process.args is required")
+	}
+	if s.Process.Terminal {
+		return errors.New("process.terminal is not supported")
+	}
+	if s.Process.Cwd == "" || !filepath.IsAbs(s.Process.Cwd) {
+		return fmt.Errorf("process.cwd %q must be an absolute path", s.Process.Cwd)
+	}
+	for _, m := range s.Mounts {
+		if !filepath.IsAbs(m.Destination) {
+			return fmt.Errorf("mount destination %q must be an absolute path", m.Destination)
+		}
+	}
+	return s.Hooks.Validate()
+}
+
+// Validate 校验钩子程序路径和超时时间，Hooks 为 nil 时直接返回
+func (h *Hooks) Validate() error {
+	if h == nil {
+		return nil
+	}
+	for _, hooks := range [][]Hook{h.Prestart, h.Poststart, h.Poststop} {
+		for _, hook := range hooks {
+			if !filepath.IsAbs(hook.Path) {
+				return fmt.Errorf("hook path %q must be an absolute path", hook.Path)
+			}
+			if hook.Timeout != nil && *hook.Timeout <= 0 {
+				return fmt.Errorf("hook %s timeout must be greater than zero", hook.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// RootfsPath 返回根文件系统的绝对路径
+func (s *Spec) RootfsPath(bundle string) string {
+	if filepath.IsAbs(s.Root.Path) {
+		return s.Root.Path
+	}
+	return filepath.Join(bundle, s.Root.Path)
+}
@@ -3,7 +3,6 @@ package db
 import (
 	"fmt"
 	"github.com/phper95/tinydocker/pkg/logger"
-	"log"
 	"os"
 	"path"
 	"sync"
@@ -20,12 +19,21 @@ type BoltDB struct {
 const DefaultBoltDBClientName = "default"
 
 var BoltDBClients = make(map[string]*BoltDB)
+
+// boltDBClientRefs 每个客户端被 InitBoltDBClient 打开的次数，CloseBoltDBClient 减到0时才真正关闭
+var boltDBClientRefs = make(map[string]int)
 var lock sync.Mutex
 
+// InitBoltDBClient 打开客户端或增加已打开客户端的引用计数，每次成功调用都需要对应一次 CloseBoltDBClient。
+// bbolt 使用文件锁，其他进程持有数据库超过超时时间时返回错误
 func InitBoltDBClient(clientName string, dbPath string) error {
 	lock.Lock()
 	defer lock.Unlock()
+	if clientName == "" {
+		clientName = DefaultBoltDBClientName
+	}
 	if _, ok := BoltDBClients[clientName]; ok {
+		boltDBClientRefs[clientName]++
 		return nil
 	}
 	// DefaultNetworkDBPath路径不存在则创建
@@ -33,13 +41,16 @@ func InitBoltDBClient(clientName string, dbPath string) error {
 	db, err := NewBoltDB(dbPath)
 	if err != nil {
 		logger.Error("init bolt db client failed clientName ", clientName, " dbPath ", dbPath, " err ", err)
-		panic(err)
+		return fmt.Errorf("open %s error: %v", dbPath, err)
 	}
 	BoltDBClients[clientName] = db
-	return err
+	boltDBClientRefs[clientName] = 1
+	return nil
 }
 
 func GetBoltDBClient(name string) *BoltDB {
+	lock.Lock()
+	defer lock.Unlock()
 	if name == "" {
 		name = DefaultBoltDBClientName
 	}
@@ -49,9 +60,30 @@ func GetBoltDBClient(name string) *BoltDB {
 	panic(fmt.Sprintf("bolt db client %s not found", name))
 }
 
+// CloseBoltDBClient 释放一次 InitBoltDBClient 的引用，最后一个引用释放时关闭并移除客户端。
+// bbolt 使用文件锁，进程只应在操作数据库期间持有连接
+func CloseBoltDBClient(clientName string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if clientName == "" {
+		clientName = DefaultBoltDBClientName
+	}
+	client, ok := BoltDBClients[clientName]
+	if !ok {
+		return nil
+	}
+	boltDBClientRefs[clientName]--
+	if boltDBClientRefs[clientName] > 0 {
+		return nil
+	}
+	delete(BoltDBClients, clientName)
+	delete(boltDBClientRefs, clientName)
+	return client.Close()
+}
+
 // NewBoltDB 创建一个新的BoltDB实例
 func NewBoltDB(dbPath string) (*BoltDB, error) {
-	log.Println("NewBoltDB dbPath ", dbPath)
+	logger.Debug("NewBoltDB dbPath %s", dbPath)
 	db, err := bbolt.Open(dbPath, 0o644, &bbolt.Options{Timeout: 5 * time.Second})
 	if err != nil {
 		return nil, err
@@ -68,6 +100,16 @@ func (b *BoltDB) Close() error {
 	return b.db.Close()
 }
 
+// View 在只读事务中执行 fn，用于需要一次读取多个 bucket 的场景
+func (b *BoltDB) View(fn func(tx *bbolt.Tx) error) error {
+	return b.db.View(fn)
+}
+
+// Update 在读写事务中执行 fn，fn 返回错误时事务回滚
+func (b *BoltDB) Update(fn func(tx *bbolt.Tx) error) error {
+	return b.db.Update(fn)
+}
+
 // CreateBucket 创建一个新的bucket
 func (b *BoltDB) CreateBucket(bucketName string) error {
 	return b.db.Update(func(tx *bbolt.Tx) error {
@@ -0,0 +1,96 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Args 解析后的 --filter 参数，同一个 key 可以指定多次
+type Args map[string][]string
+
+// Parse 解析 key=value 形式的过滤条件，allowed 为支持的 key
+func Parse(filters []string, allowed ...string) (Args, error) {
+	args := Args{}
+	for _, f := range filters {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected key=value", f)
+		}
+		if !contains(allowed, key) {
+			return nil, fmt.Errorf("invalid filter key %q, supported: %s", key, strings.Join(allowed, ", "))
+		}
+		args[key] = append(args[key], value)
+	}
+	return args, nil
+}
+
+// Get 返回 key 对应的所有值
+func (a Args) Get(key string) []string {
+	return a[key]
+}
+
+// Match 判断 value 是否满足 key 的过滤条件：没有指定该 key 或与任意一个值相等时满足
+func (a Args) Match(key, value string) bool {
+	values, ok := a[key]
+	if !ok {
+		return true
+	}
+	return contains(values, value)
+}
+
+// MatchContains 判断 value 是否满足 key 的过滤条件：没有指定该 key 或包含任意一个值时满足
+func (a Args) MatchContains(key, value string) bool {
+	values, ok := a[key]
+	if !ok {
+		return true
+	}
+	for _, v := range values {
+		if strings.Contains(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Has 判断是否指定了 key 的过滤条件
+func (a Args) Has(key string) bool {
+	_, ok := a[key]
+	return ok
+}
+
+// MatchLabels 判断标签是否满足所有 label 过滤条件，条件为 label=key 或 label=key=value
+func (a Args) MatchLabels(labels map[string]string) bool {
+	for _, f := range a["label"] {
+		key, value, hasValue := strings.Cut(f, "=")
+		v, ok := labels[key]
+		if !ok || (hasValue && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabels 解析 --label key=value 参数，只有 key 时值为空字符串
+func ParseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(labels))
+	for _, l := range labels {
+		key, value, _ := strings.Cut(l, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", l)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
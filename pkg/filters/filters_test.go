@@ -0,0 +1,97 @@
+package filters
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	args, err := Parse([]string{"name=web", "label=app", "name=db", "label=tier=backend"}, "name", "label")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	want := Args{"name": {"web", "db"}, "label": {"app", "tier=backend"}}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Parse = %v, want %v", args, want)
+	}
+
+	for _, f := range []string{"status=running", "name", "name=", "=web"} {
+		if args, err := Parse([]string{f}, "name", "label"); err == nil {
+			t.Errorf("Parse(%q) = %v, want error", f, args)
+		}
+	}
+}
+
+// 同一个 key 的多个值之间是或的关系，没有指定的 key 不参与过滤
+func TestMatch(t *testing.T) {
+	args := Args{"status": {"running", "paused"}, "name": {"web", "api"}}
+	cases := []struct {
+		match func(key, value string) bool
+		key   string
+		value string
+		want  bool
+	}{
+		{args.Match, "status", "running", true},
+		{args.Match, "status", "paused", true},
+		{args.Match, "status", "stopped", false},
+		{args.Match, "network", "anything", true},
+		{args.MatchContains, "name", "my-web-1", true},
+		{args.MatchContains, "name", "api", true},
+		{args.MatchContains, "name", "db", false},
+		{args.MatchContains, "ancestor", "busybox", true},
+	}
+	for _, c := range cases {
+		if got := c.match(c.key, c.value); got != c.want {
+			t.Errorf("match(%q, %q) = %v, want %v", c.key, c.value, got, c.want)
+		}
+	}
+	if !args.Has("name") || args.Has("label") {
+		t.Errorf("Has: name=%v label=%v", args.Has("name"), args.Has("label"))
+	}
+}
+
+// label 条件之间是与的关系：label=key 只要求存在该标签，label=key=value 还要求值相等
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"app": "shop", "tier": "backend", "empty": ""}
+	match := map[string]bool{
+		"app":                 true,
+		"app=shop":            true,
+		"app=blog":            false,
+		"version":             false,
+		"empty":               true,
+		"empty=":              true,
+		"tier=backend":        true,
+		"tier=backend=stable": false,
+	}
+	for f, want := range match {
+		if got := (Args{"label": {f}}).MatchLabels(labels); got != want {
+			t.Errorf("label=%s matched %v, want %v", f, got, want)
+		}
+	}
+	if !(Args{"label": {"app=shop", "tier"}}).MatchLabels(labels) {
+		t.Error("all matching label filters should match")
+	}
+	if (Args{"label": {"app=shop", "tier=frontend"}}).MatchLabels(labels) {
+		t.Error("label filters should be ANDed")
+	}
+	if !(Args{}).MatchLabels(nil) || (Args{"label": {"app"}}).MatchLabels(nil) {
+		t.Error("unexpected match for containers without labels")
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	got, err := ParseLabels([]string{"app=shop", "debug", "url=http://x/?a=b", "app=blog"})
+	if err != nil {
+		t.Fatalf("ParseLabels error: %v", err)
+	}
+	want := map[string]string{"app": "blog", "debug": "", "url": "http://x/?a=b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLabels = %v, want %v", got, want)
+	}
+	if got, err := ParseLabels(nil); got != nil || err != nil {
+		t.Errorf("ParseLabels(nil) = %v, %v", got, err)
+	}
+	if _, err := ParseLabels([]string{"=value"}); err == nil {
+		t.Error("ParseLabels with an empty key succeeded, want error")
+	}
+}